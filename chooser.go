@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/charmbracelet/bubbles/key"
+	listview "github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// chooser lets the user pick a page when several sections match, like
+// printf(1) and printf(3).
+type chooser struct {
+	list   listview.Model
+	paths  []string
+	chosen string
+}
+
+var (
+	chooseKey = key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "open"))
+	cancelKey = key.NewBinding(key.WithKeys("q", "esc", "ctrl+c"), key.WithHelp("q", "quit"))
+)
+
+func newChooser(target string, paths []string) chooser {
+	var items []listview.Item
+	for _, path := range paths {
+		items = append(items, navItem(fmt.Sprintf("%s(%s)  %s", target, manSection(path), path)))
+	}
+
+	l := listview.New(items, navItemDelegate{}, 0, 0)
+	l.Title = fmt.Sprintf("Multiple pages found for %s", target)
+	l.SetShowStatusBar(false)
+	l.SetShowHelp(false)
+	l.SetFilteringEnabled(false)
+
+	return chooser{list: l, paths: paths}
+}
+
+func (c chooser) Init() tea.Cmd {
+	return nil
+}
+
+func (c chooser) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, chooseKey):
+			c.chosen = c.paths[c.list.Index()]
+			return c, tea.Quit
+		case key.Matches(msg, cancelKey):
+			return c, tea.Quit
+		}
+	case tea.WindowSizeMsg:
+		c.list.SetSize(msg.Width, msg.Height)
+	}
+
+	var cmd tea.Cmd
+	c.list, cmd = c.list.Update(msg)
+	return c, cmd
+}
+
+func (c chooser) View() string {
+	return c.list.View()
+}
+
+// Ask the user which of paths to open. Returns "" if they quit without
+// choosing.
+func chooseManPage(target string, paths []string) (string, error) {
+	m, err := tea.NewProgram(newChooser(target, paths)).Run()
+	if err != nil {
+		return "", err
+	}
+	return m.(chooser).chosen, nil
+}
+
+// Print the pages that match target, for when there's no one to ask which to
+// open.
+func listCandidates(w io.Writer, target string, paths []string) {
+	fmt.Fprintf(w, "several pages match %q, pick one with a section or a path:\n", target)
+	for _, path := range paths {
+		fmt.Fprintf(w, "  %s(%s)  %s\n", target, manSection(path), path)
+	}
+}
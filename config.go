@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// Key bindings can be changed in ~/.config/doc/keys.toml, e.g.
+//
+//	page_down = ["ctrl+v", "pgdown"]
+//	begin_search = ["ctrl+s", "/"]
+//
+// Actions that aren't listed keep their default keys.
+func keyConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "doc", "keys.toml")
+}
+
+// The configurable actions, by the name used in the config file.
+func (k *keyMap) actions() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"page_down":       &k.PageDown,
+		"page_up":         &k.PageUp,
+		"half_page_up":    &k.HalfPageUp,
+		"half_page_down":  &k.HalfPageDown,
+		"down":            &k.Down,
+		"up":              &k.Up,
+		"top":             &k.Top,
+		"bottom":          &k.Bottom,
+		"scroll_left":     &k.ScrollLeft,
+		"scroll_right":    &k.ScrollRight,
+		"navigate":        &k.Navigate,
+		"toggle_sidebar":  &k.ToggleSidebar,
+		"grow_sidebar":    &k.GrowSidebar,
+		"shrink_sidebar":  &k.ShrinkSidebar,
+		"set_mark":        &k.SetMark,
+		"jump_to_mark":    &k.JumpToMark,
+		"jump_to_section": &k.JumpToSection,
+		"begin_search":    &k.BeginSearch,
+		"clear_search":    &k.ClearSearch,
+		"next":            &k.Next,
+		"previous":        &k.Previous,
+		"help":            &k.Help,
+		"quit":            &k.Quit,
+	}
+}
+
+func (sk *searchKeyMap) actions() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"submit_search":       &sk.SubmitSearch,
+		"cancel_search":       &sk.Cancel,
+		"toggle_whole_word":   &sk.ToggleWholeWord,
+		"toggle_section_only": &sk.ToggleSectionOnly,
+		"previous_search":     &sk.PreviousSearch,
+		"next_search":         &sk.NextSearch,
+	}
+}
+
+// How a key is shown in the help view.
+func keyHelp(keys []string) string {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		if k == " " {
+			k = "space"
+		}
+		names[i] = k
+	}
+	return strings.Join(names, "/")
+}
+
+// Override keys and searchKeys with the bindings in the config file at path.
+// A missing file isn't an error. If the file is invalid nothing is changed.
+func loadKeyConfig(path string, keys *keyMap, searchKeys *searchKeyMap) error {
+	if path == "" {
+		return nil
+	}
+
+	var config map[string][]string
+	if _, err := toml.DecodeFile(path, &config); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	actions := keys.actions()
+	for name, binding := range searchKeys.actions() {
+		actions[name] = binding
+	}
+
+	// check everything before changing any bindings
+	for name, keys := range config {
+		if _, ok := actions[name]; !ok {
+			return fmt.Errorf("unknown action %q", name)
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("no keys for %q", name)
+		}
+	}
+
+	for name, keys := range config {
+		binding := actions[name]
+		binding.SetKeys(keys...)
+		binding.SetHelp(keyHelp(keys), binding.Help().Desc)
+	}
+	return nil
+}
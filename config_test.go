@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeKeyConfig(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "keys.toml")
+	if err := os.WriteFile(path, []byte(contents), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadKeyConfig(t *testing.T) {
+	path := writeKeyConfig(t, `
+page_down = ["ctrl+v", " "]
+cancel_search = ["ctrl+g"]
+`)
+	keys, searchKeys := defaultKeyMap(), defaultSearchKeyMap()
+	if err := loadKeyConfig(path, &keys, &searchKeys); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(keys.PageDown.Keys(), []string{"ctrl+v", " "}) {
+		t.Errorf("page down keys = %v", keys.PageDown.Keys())
+	}
+	if keys.PageDown.Help().Key != "ctrl+v/space" {
+		t.Errorf("page down help = %q", keys.PageDown.Help().Key)
+	}
+	if !slices.Equal(searchKeys.Cancel.Keys(), []string{"ctrl+g"}) {
+		t.Errorf("cancel keys = %v", searchKeys.Cancel.Keys())
+	}
+	if !slices.Equal(keys.Quit.Keys(), defaultKeyMap().Quit.Keys()) {
+		t.Errorf("quit keys changed to %v", keys.Quit.Keys())
+	}
+}
+
+func TestLoadKeyConfigErrors(t *testing.T) {
+	for _, contents := range []string{
+		`page_down = ["ctrl+v"]` + "\n" + `jump = ["x"]`,
+		`quit = []`,
+		`quit = "q"`,
+	} {
+		keys, searchKeys := defaultKeyMap(), defaultSearchKeyMap()
+		if err := loadKeyConfig(writeKeyConfig(t, contents), &keys, &searchKeys); err == nil {
+			t.Errorf("%q: expected an error", contents)
+		}
+		if !slices.Equal(keys.PageDown.Keys(), defaultKeyMap().PageDown.Keys()) {
+			t.Errorf("%q: page down keys changed to %v", contents, keys.PageDown.Keys())
+		}
+	}
+
+	keys, searchKeys := defaultKeyMap(), defaultSearchKeyMap()
+	if err := loadKeyConfig(filepath.Join(t.TempDir(), "missing.toml"), &keys, &searchKeys); err != nil {
+		t.Errorf("missing file: %v", err)
+	}
+}
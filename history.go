@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Most searches to remember
+const maxSearchHistory = 100
+
+// Searches are saved in ~/.cache/doc/search_history, one per line.
+func searchHistoryPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "doc", "search_history")
+}
+
+// Read the saved searches, oldest first. A missing file is an empty history.
+func loadSearchHistory(path string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var history []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			history = append(history, line)
+		}
+	}
+	return history
+}
+
+func saveSearchHistory(path string, history []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(history, "\n")+"\n"), 0644)
+}
+
+// Add query to the end of history, unless it's the same as the last search.
+func addToHistory(history []string, query string) []string {
+	if query == "" || strings.Contains(query, "\n") {
+		return history
+	}
+	if len(history) > 0 && history[len(history)-1] == query {
+		return history
+	}
+	history = append(history, query)
+	if len(history) > maxSearchHistory {
+		history = history[len(history)-maxSearchHistory:]
+	}
+	return history
+}
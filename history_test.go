@@ -0,0 +1,32 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestAddToHistory(t *testing.T) {
+	var history []string
+	for _, query := range []string{"foo", "foo", "", "bar", "foo"} {
+		history = addToHistory(history, query)
+	}
+	if expected := []string{"foo", "bar", "foo"}; !slices.Equal(history, expected) {
+		t.Errorf("history = %q, wanted %q", history, expected)
+	}
+}
+
+func TestSearchHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc", "search_history")
+	if history := loadSearchHistory(path); history != nil {
+		t.Errorf("missing history file gave %q", history)
+	}
+
+	history := []string{"-l", "SEE ALSO"}
+	if err := saveSearchHistory(path, history); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := loadSearchHistory(path); !slices.Equal(loaded, history) {
+		t.Errorf("loaded %q, wanted %q", loaded, history)
+	}
+}
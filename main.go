@@ -2,19 +2,42 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"compress/gzip"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/benwaffle/doc/mandoc"
 	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
 )
 
+// The section a man or cat directory holds, e.g. "1" for man1 and cat1.
+func sectionOfDir(dir string) string {
+	name := filepath.Base(dir)
+	if strings.HasPrefix(name, "cat") {
+		return strings.TrimPrefix(name, "cat")
+	}
+	return strings.TrimPrefix(name, "man")
+}
+
 func findDocInManSection(sectionDir, target string) string {
-	section := strings.TrimPrefix(filepath.Base(sectionDir), "man")
+	section := sectionOfDir(sectionDir)
 	fullTarget := fmt.Sprintf("%s.%s", target, section)
 	fullTargetGz := fmt.Sprintf("%s.%s.gz", target, section)
 
@@ -25,71 +48,480 @@ func findDocInManSection(sectionDir, target string) string {
 
 	for _, file := range files {
 		if file.Name() == fullTarget || file.Name() == fullTargetGz {
-			return sectionDir + "/" + file.Name()
+			// aliases like gunzip.1 -> gzip.1 are symlinks, use the real page
+			path, err := filepath.EvalSymlinks(sectionDir + "/" + file.Name())
+			if err != nil { // dangling symlink
+				continue
+			}
+			return path
 		}
 	}
 	return ""
 }
 
-func findDocInManDir(mandir, target string) string {
+func findDocsInManDir(mandir, target string) []string {
 	dirs, err := os.ReadDir(mandir)
 	if err != nil {
 		panic(err)
 	}
 
-	for _, dir := range dirs {
-		if strings.HasPrefix(dir.Name(), "man") {
-			path := findDocInManSection(mandir+"/"+dir.Name(), target)
-			if path != "" {
-				return path
+	// prefer source pages to preformatted cat pages
+	var paths []string
+	for _, prefix := range []string{"man", "cat"} {
+		for _, dir := range dirs {
+			if strings.HasPrefix(dir.Name(), prefix) {
+				path := findDocInManSection(mandir+"/"+dir.Name(), target)
+				if path != "" {
+					paths = append(paths, path)
+				}
 			}
 		}
 	}
-	return ""
+	return paths
 }
 
-func findDoc(target string) string {
-	manPath := os.Getenv("MANPATH")
-	if len(manPath) > 0 {
-		for _, dir := range strings.Split(manPath, ":") {
-			if len(dir) == 0 {
-				continue
-			}
-			path := findDocInManDir(dir, target)
-			if path != "" {
-				return path
+// The section of a man page, from the directory it's in, e.g. "3" for
+// /usr/share/man/man3/printf.3.gz.
+func manSection(path string) string {
+	return sectionOfDir(filepath.Dir(path))
+}
+
+// Locale names to try, most specific first, e.g. fr_FR.UTF-8 gives
+// [fr_FR.UTF-8 fr_FR fr].
+func localeNames() []string {
+	locale := ""
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale = os.Getenv(env); locale != "" {
+			break
+		}
+	}
+	locale, _, _ = strings.Cut(locale, "@") // drop modifier
+	if locale == "" || locale == "C" || locale == "POSIX" || strings.HasPrefix(locale, "C.") {
+		return nil
+	}
+
+	names := []string{locale}
+	if territory, _, found := strings.Cut(locale, "."); found {
+		names = append(names, territory)
+	}
+	if language, _, found := strings.Cut(locale, "_"); found {
+		names = append(names, language)
+	}
+	return names
+}
+
+// Look for translated pages in the locale subdirectories of mandir before
+// the untranslated pages.
+func findLocalizedDocsInManDir(mandir, target string) []string {
+	var paths []string
+	for _, locale := range localeNames() {
+		localeDir := filepath.Join(mandir, locale)
+		if info, err := os.Stat(localeDir); err != nil || !info.IsDir() {
+			continue
+		}
+		paths = append(paths, findDocsInManDir(localeDir, target)...)
+	}
+	return append(paths, findDocsInManDir(mandir, target)...)
+}
+
+// Conventional section search order, used when MANSECT is unset.
+const defaultManSect = "1:8:2:3:n:4:5:6:7:9:l"
+
+// Position of section in the MANSECT search order. Subsections like 3p rank
+// with their parent section unless they're listed themselves.
+func sectionRank(order []string, section string) int {
+	if i := slices.Index(order, section); i != -1 {
+		return i
+	}
+	for i, s := range order {
+		if strings.HasPrefix(section, s) {
+			return i
+		}
+	}
+	return len(order)
+}
+
+// Sort paths by the section order in MANSECT.
+func sortBySection(paths []string) {
+	manSect := os.Getenv("MANSECT")
+	if manSect == "" {
+		manSect = defaultManSect
+	}
+	order := strings.Split(manSect, ":")
+	slices.SortStableFunc(paths, func(a, b string) int {
+		return sectionRank(order, manSection(a)) - sectionRank(order, manSection(b))
+	})
+}
+
+// Used when the system manpath command isn't available.
+var defaultManDirs = []string{
+	"/usr/local/share/man",
+	"/usr/share/man",
+	"/usr/local/man",
+	"/opt/homebrew/share/man",
+	"/opt/local/share/man",
+}
+
+// The command that prints the directories man would search.
+var manpathCommand = []string{"manpath"}
+
+// Ask the system manpath command for the directories man would search. Falls
+// back to defaultManDirs.
+func systemManDirs() []string {
+	out, err := exec.Command(manpathCommand[0], manpathCommand[1:]...).Output()
+	manPath := strings.TrimSpace(string(out))
+	if err != nil || manPath == "" {
+		return defaultManDirs
+	}
+	return strings.Split(manPath, ":")
+}
+
+// Directories to search for man pages: MANPATH if it's set, otherwise the
+// system directories. Directories that don't exist are skipped.
+func manDirs() []string {
+	var candidates []string
+	if manPath := os.Getenv("MANPATH"); len(manPath) > 0 {
+		candidates = strings.Split(manPath, ":")
+	} else {
+		candidates = systemManDirs()
+	}
+
+	var dirs []string
+	for _, dir := range candidates {
+		if len(dir) == 0 || slices.Contains(dirs, dir) {
+			continue
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// Find every page for target, in order of preference. Only the first page for
+// each section is kept, so a translated page hides the untranslated one.
+func findDocs(target string) []string {
+	var paths []string
+	seen := map[string]bool{}
+	for _, dir := range manDirs() {
+		for _, path := range findLocalizedDocsInManDir(dir, target) {
+			if !seen[manSection(path)] {
+				seen[manSection(path)] = true
+				paths = append(paths, path)
 			}
 		}
 	}
-	// TODO: locale support
-	return findDocInManDir("/usr/share/man", target)
+	sortBySection(paths)
+	return paths
+}
+
+// How much of a page is looked at before it's parsed
+const pagePeek = 64 * 1024
+
+// A man page opened for reading, decompressed and decoded to UTF-8.
+type manPage struct {
+	*bufio.Reader
+	file *os.File
+}
+
+func (page *manPage) Close() error {
+	return page.file.Close()
+}
+
+// The start of the page, where a .so stub or a preprocessor hint is recognized.
+func (page *manPage) start() (string, error) {
+	prefix, err := page.Peek(pagePeek)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", err
+	}
+	return string(prefix), nil
 }
 
-func readManPage(path string) (string, error) {
+// Open the page at path, decompressing it if it's gzipped.
+func openManPage(path string) (*manPage, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		return "", nil
+		return nil, err
 	}
-	defer file.Close()
 
 	var reader io.Reader = bufio.NewReader(file)
-
 	if strings.HasSuffix(path, ".gz") {
 		gzipReader, err := gzip.NewReader(reader)
 		if err != nil {
-			return "", err
+			file.Close()
+			return nil, err
 		}
 		reader = gzipReader
 	}
-	data, err := io.ReadAll(reader)
+	decoded, err := decodeManPage(path, reader)
 	if err != nil {
-		return "", err
+		file.Close()
+		return nil, err
+	}
+	return &manPage{decoded, file}, nil
+}
+
+// Read the whole page at path.
+func readManPage(path string) ([]byte, error) {
+	page, err := openManPage(path)
+	if err != nil {
+		return nil, err
+	}
+	defer page.Close()
+	return io.ReadAll(page)
+}
+
+// How many .so stubs in a row are followed before giving up
+const maxSoDepth = 8
+
+// The page a stub like ".so man1/gzip.1" includes, or "" if the source isn't a
+// stub. Comments and blank lines before the request are skipped.
+func soTarget(data string) string {
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || line == "." || strings.HasPrefix(line, `.\"`) || strings.HasPrefix(line, `'\"`):
+			continue
+		case strings.HasPrefix(line, ".so "):
+			return strings.TrimSpace(line[4:])
+		}
+		return ""
+	}
+	return ""
+}
+
+// Follow .so stubs from the page at path to the page with the real source.
+// Relative includes are from the top of the man directory, like man1/gzip.1,
+// and may be compressed. Stubs are closed as they're followed, and page is
+// closed if there's an error.
+func resolveSource(path string, page *manPage) (string, *manPage, error) {
+	for i := 0; i < maxSoDepth; i++ {
+		start, err := page.start()
+		if err != nil {
+			page.Close()
+			return "", nil, err
+		}
+		target := soTarget(start)
+		if target == "" {
+			return path, page, nil
+		}
+		page.Close()
+
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(filepath.Dir(path)), target)
+		}
+		if _, err := os.Stat(target); err != nil {
+			if _, gzErr := os.Stat(target + ".gz"); gzErr != nil {
+				return "", nil, err
+			}
+			target += ".gz"
+		}
+
+		if page, err = openManPage(target); err != nil {
+			return "", nil, err
+		}
+		path = target
+	}
+	page.Close()
+	return "", nil, fmt.Errorf("%s: too many nested .so requests", path)
+}
+
+// Older and localized man pages may not be UTF-8. Use the encoding named by the
+// locale directory (e.g. /usr/share/man/fr.ISO8859-1/man1) if there is one,
+// otherwise assume latin1. Pages are decoded as they're read, so the encoding
+// is decided from the start of the page.
+func decodeManPage(path string, r io.Reader) (*bufio.Reader, error) {
+	reader := bufio.NewReaderSize(r, pagePeek)
+	prefix, err := reader.Peek(pagePeek)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
 	}
+	if len(prefix) == pagePeek { // a character may be cut off at the end
+		prefix = trimPartialRune(prefix)
+	}
+	if utf8.Valid(prefix) {
+		return reader, nil
+	}
+
+	var enc encoding.Encoding = charmap.ISO8859_1
+	for _, dir := range strings.Split(filepath.Dir(path), string(filepath.Separator)) {
+		_, charset, found := strings.Cut(dir, ".")
+		if !found {
+			continue
+		}
+		if e, err := htmlindex.Get(charset); err == nil {
+			enc = e
+		}
+	}
+	return bufio.NewReaderSize(transform.NewReader(reader, enc.NewDecoder()), pagePeek), nil
+}
 
-	return string(data), nil
+// Remove an incomplete UTF-8 character from the end of data.
+func trimPartialRune(data []byte) []byte {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+// Read and parse the page at path.
+func loadManPage(path string) (mandoc.Page, error) {
+	source, err := openManPage(path)
+	if err != nil {
+		return mandoc.Page{}, err
+	}
+	if path, source, err = resolveSource(path, source); err != nil {
+		return mandoc.Page{}, err
+	}
+	defer source.Close()
+	start, err := source.start()
+	if err != nil {
+		return mandoc.Page{}, err
+	}
+
+	var input io.Reader = source
+	if alwaysRenderExternally || preprocessors(start) != "" {
+		data, err := io.ReadAll(source)
+		if err != nil {
+			return mandoc.Page{}, err
+		}
+		rendered, err := renderExternally(path, data)
+		if err == nil || alwaysRenderExternally {
+			return rendered, err
+		}
+		// tables and equations are only readable after groff's preprocessors,
+		// but our own rendering is still better than nothing
+		input = bytes.NewReader(data)
+	}
+
+	page, err := mandoc.Parse(input, pageFormat)
+	if err != nil {
+		// show what man makes of pages we can't parse
+		if data, readErr := readManPage(path); readErr == nil {
+			if rendered, renderErr := renderExternally(path, data); renderErr == nil {
+				return rendered, nil
+			}
+		}
+		return mandoc.Page{}, fmt.Errorf("%s: %w", path, err)
+	}
+	defaultTitle(page, path)
+	return *page, nil
 }
 
-func dumpAst(page manPage) {
+// Render every page with man or groff instead of parsing it, set by -fallback
+var alwaysRenderExternally = false
+
+// The format to parse pages as, set by -type
+var pageFormat = mandoc.FormatAuto
+
+// Commands that format a page read from stdin as overstruck text, tried in
+// order until one is installed.
+var externalRenderers = [][]string{
+	{"man", "-l", "-"},
+	{"groff", "-Tutf8", "-mandoc", "-P-c"},
+}
+
+// groff options that run the preprocessor for each letter of a hint line.
+var groffPreprocessors = map[rune]string{
+	't': "-t", // tbl
+	'e': "-e", // eqn
+	'p': "-p", // pic
+	'g': "-G", // grap
+	'r': "-R", // refer
+}
+
+// The preprocessors a page needs, from a hint on its first line like '\" te
+// for tbl and eqn. Returns only the letters groff knows.
+func preprocessors(data string) string {
+	line, _, _ := strings.Cut(data, "\n")
+	hint, ok := strings.CutPrefix(line, `'\" `)
+	if !ok {
+		hint, ok = strings.CutPrefix(line, `.\" `)
+	}
+	if !ok {
+		return ""
+	}
+	hint, _, _ = strings.Cut(strings.TrimSpace(hint), " ")
+
+	res := ""
+	for _, letter := range hint {
+		if _, ok := groffPreprocessors[letter]; !ok {
+			return "" // an ordinary comment, not a hint
+		}
+		res += string(letter)
+	}
+	return res
+}
+
+var errNoRenderer = errors.New("no man or groff to format it with")
+
+// Format a page with the system's man or groff, and parse the output as a cat
+// page.
+func renderExternally(path string, data []byte) (mandoc.Page, error) {
+	for _, args := range externalRenderers {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		if args[0] == "groff" { // man reads the hint line itself
+			args = append(slices.Clip(args), fmt.Sprintf("-rLL=%dn", outputWidth()))
+			for _, letter := range preprocessors(string(data)) {
+				args = append(args, groffPreprocessors[letter])
+			}
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Env = append(os.Environ(), "MAN_KEEP_FORMATTING=1", "MANPAGER=cat", "PAGER=cat",
+			fmt.Sprintf("MANWIDTH=%d", outputWidth()))
+		out, err := cmd.Output()
+		if err != nil || len(out) == 0 {
+			continue
+		}
+
+		page, err := mandoc.Parse(bytes.NewReader(out), mandoc.FormatAuto)
+		if err != nil {
+			return mandoc.Page{}, fmt.Errorf("%s: %w", path, err)
+		}
+		defaultTitle(page, path)
+		return *page, nil
+	}
+	return mandoc.Page{}, fmt.Errorf("%s: %w", path, errNoRenderer)
+}
+
+// Fill in a missing name and section from the file name, e.g. "frob" and 3
+// for frob.3.gz, so pages without .TH or .Dt still have a title.
+func defaultTitle(page *mandoc.Page, path string) {
+	base := strings.TrimSuffix(filepath.Base(path), ".gz")
+	name, section := base, ""
+	if i := strings.LastIndex(base, "."); i > 0 {
+		name, section = base[:i], base[i+1:]
+	}
+
+	if page.Name == "" {
+		page.Name = name
+	}
+	if page.Section == "" && section != "" && (unicode.IsDigit(rune(section[0])) || section == "n" || section == "l") {
+		page.Section = section
+	}
+}
+
+// Find pages for target in section, or in any section if section is "".
+func findDocsInSection(target, section string) []string {
+	var paths []string
+	for _, path := range findDocs(target) {
+		if section == "" || manSection(path) == section {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+func dumpAst(page mandoc.Page) {
 	bytes, err := json.Marshal(page)
 	if err != nil {
 		panic(err)
@@ -97,41 +529,276 @@ func dumpAst(page manPage) {
 	os.WriteFile("ast.json", bytes, 0666)
 }
 
+// Print each warning for the page at path like a compiler error. Returns
+// whether there were any.
+func reportWarnings(w io.Writer, path string, page mandoc.Page) bool {
+	for _, warning := range page.Warnings {
+		switch {
+		case warning.Macro != "":
+			fmt.Fprintf(w, "%s:%d: unknown macro .%s\n", path, warning.Line, warning.Macro)
+		case warning.Misplaced != "":
+			fmt.Fprintf(w, "%s:%d: misplaced .%s ignored\n", path, warning.Line, warning.Misplaced)
+		default:
+			fmt.Fprintf(w, "%s:%d: shown as plain text: %s\n", path, warning.Line, warning.Raw)
+		}
+	}
+	return len(page.Warnings) > 0
+}
+
+// How pages are rendered, set by -tabwidth, -columns, -markers, and friends
+var renderOptions = mandoc.DefaultOptions()
+
+// Width of pages written with -output, unless -width is set
+const defaultOutputWidth = 80
+
+// Width of pages written with -output or formatted by man or groff.
+func outputWidth() int {
+	if pageWidth > 0 {
+		return pageWidth
+	}
+	return defaultOutputWidth
+}
+
+// The page as plain text, wrapped to width.
+func renderPlain(page mandoc.Page, width int) string {
+	return stripAnsi(mandoc.Wrap(page.Render(width, renderOptions), width)) + "\n"
+}
+
+// Write the page as plain text to path, or stdout if path is "-".
+func writePage(page mandoc.Page, path string) error {
+	text := renderPlain(page, outputWidth())
+	if path == "-" {
+		_, err := io.WriteString(os.Stdout, text)
+		return err
+	}
+	return os.WriteFile(path, []byte(text), 0666)
+}
+
+// Whether the locale's character set is UTF-8, going by the first of LC_ALL,
+// LC_CTYPE, and LANG that's set. Without any of them, assume it is.
+func utf8Locale() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := os.Getenv(name); locale != "" {
+			locale = strings.ToLower(locale)
+			return strings.Contains(locale, "utf-8") || strings.Contains(locale, "utf8")
+		}
+	}
+	return true
+}
+
+// Whether the terminal is one known to make OSC 8 links clickable. There's no
+// way to ask, so this goes by the variables terminals set.
+func terminalHyperlinks() bool {
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "ghostty", "Hyper":
+		return true
+	}
+	if vte, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && vte >= 5000 {
+		return true
+	}
+	if os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("WT_SESSION") != "" || os.Getenv("KONSOLE_VERSION") != "" {
+		return true
+	}
+	term := os.Getenv("TERM")
+	return strings.HasPrefix(term, "foot") || term == "alacritty" || term == "xterm-kitty"
+}
+
+// Set at build time with -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [section] <command | file>\n\n", os.Args[0])
+	fmt.Fprintf(out, "Show the man page for command, optionally from the given section.\n")
+	fmt.Fprintf(out, "If more than one section matches, you'll be asked to choose.\n\n")
+	fmt.Fprintf(out, "Flags:\n")
+	flag.PrintDefaults()
+}
+
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s <command>\n", os.Args[0])
+	showVersion := flag.Bool("version", false, "print the version and exit")
+	flag.IntVar(&renderOptions.TabWidth, "tabwidth", renderOptions.TabWidth, "width of tab stops in preformatted text")
+	flag.BoolVar(&renderOptions.TwoColumns, "columns", renderOptions.TwoColumns, "show running text in two columns in wide windows")
+	flag.IntVar(&renderOptions.TwoColumnMinWidth, "columns-min-width", renderOptions.TwoColumnMinWidth, "narrowest window to use two columns in")
+	hyperlinks := flag.String("hyperlinks", "auto", "make links clickable with OSC 8 escapes: always, never, or auto to when showing the page in a terminal known to support them")
+	flag.BoolVar(&renderOptions.HighlightExamples, "highlight-examples", renderOptions.HighlightExamples, "color the shell commands in EXAMPLES sections")
+	flag.IntVar(&narrowWindowWidth, "sidebar-min-window-width", narrowWindowWidth, "hide the sidebar in windows narrower than this")
+	flag.IntVar(&minContentWidth, "min-width", minContentWidth, "scroll sideways instead of wrapping in windows narrower than this, or 0 to always wrap")
+	flag.IntVar(&pageWidth, "width", pageWidth, "render pages this many columns wide instead of fitting the window, or 80 with -output")
+	flag.BoolVar(&bellOnNoMatch, "bell", bellOnNoMatch, "ring the terminal bell when a search finds nothing")
+	keyPreset := flag.String("keys", "default", "key bindings to use: default, emacs, or less")
+	markers := flag.String("markers", "auto", "list markers to use: unicode, ascii, or auto to pick from the locale")
+	bullet := flag.String("bullet", "", "marker for bulleted list items")
+	dash := flag.String("description-dash", "", "dash between a page's names and its description, by default an en dash if the locale is UTF-8")
+	flag.BoolVar(&alwaysRenderExternally, "fallback", alwaysRenderExternally, "format pages with the system man or groff instead of parsing them")
+	format := flag.String("type", "auto", "parse pages as mdoc, man, or auto to detect the format")
+	raw := flag.Bool("raw", false, "print the page's source, without following a .so stub, and exit")
+	rawResolved := flag.Bool("raw-resolved", false, "print the page's source, after following .so stubs, and exit")
+	strict := flag.Bool("strict", false, "report macros and escapes that can't be rendered and exit, with status 1 if there are any")
+	var output string
+	flag.StringVar(&output, "output", "", "write the page as plain text to this file, or - for stdout, instead of showing it")
+	flag.StringVar(&output, "o", "", "shorthand for -output")
+	flag.Usage = usage
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(version)
+		return
+	}
+
+	if pageWidth < 0 {
+		fmt.Fprintf(os.Stderr, "invalid width %d\n", pageWidth)
+		os.Exit(1)
+	} else if pageWidth > 0 {
+		pageWidth = max(pageWidth, minPageWidth)
+	}
+
+	newKeyMap, ok := keyPresets[*keyPreset]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown key bindings %q\n", *keyPreset)
+		os.Exit(1)
+	}
+
+	switch *markers {
+	case "unicode":
+		renderOptions.Markers = mandoc.DefaultMarkers
+	case "ascii":
+		renderOptions.Markers = mandoc.ASCIIMarkers
+	case "auto":
+		if !utf8Locale() {
+			renderOptions.Markers = mandoc.ASCIIMarkers
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown list markers %q\n", *markers)
+		os.Exit(1)
+	}
+	if *bullet != "" {
+		renderOptions.Markers.Bullet = *bullet
+	}
+	switch {
+	case *dash != "":
+		renderOptions.DescriptionDash = *dash
+	case !utf8Locale():
+		renderOptions.DescriptionDash = "-"
+	}
+
+	switch *hyperlinks {
+	case "always":
+		renderOptions.Hyperlinks = true
+	case "never":
+	case "auto":
+		renderOptions.Hyperlinks = output == "" && terminalHyperlinks()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown hyperlinks setting %q\n", *hyperlinks)
+		os.Exit(1)
+	}
+
+	var err error
+	if pageFormat, err = mandoc.ParseFormat(*format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var section, target string
+	switch flag.NArg() {
+	case 1:
+		target = flag.Arg(0)
+	case 2:
+		section, target = flag.Arg(0), flag.Arg(1)
+	default:
+		usage()
 		os.Exit(1)
 	}
 
-	target := os.Args[1]
 	var manFile string
 
 	if _, err := os.Stat(target); err == nil {
 		manFile = target
 	} else {
-		manFile = findDoc(target)
-		if manFile == "" {
+		candidates := findDocsInSection(target, section)
+		switch len(candidates) {
+		case 0:
 			fmt.Fprintf(os.Stderr, "cannot find man page for \"%s\"\n", target)
 			os.Exit(1)
+		case 1:
+			manFile = candidates[0]
+		default:
+			if output != "" || *raw || *rawResolved || *strict { // nobody to ask
+				listCandidates(os.Stderr, target, candidates)
+				os.Exit(1)
+			}
+			chosen, err := chooseManPage(target, candidates)
+			if err != nil {
+				fmt.Println("could not run program:", err)
+				os.Exit(1)
+			}
+			if chosen == "" {
+				os.Exit(0)
+			}
+			manFile = chosen
 		}
 	}
 
-	fmt.Println(manFile)
+	if *raw || *rawResolved {
+		source, err := openManPage(manFile)
+		if err == nil && *rawResolved {
+			_, source, err = resolveSource(manFile, source)
+		}
+		if err == nil {
+			_, err = io.Copy(os.Stdout, source)
+			source.Close()
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	data, err := readManPage(manFile)
-	if err != nil {
-		panic(err)
+	page, err := loadManPage(manFile)
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		// the file was removed after it was found, or can't be read
+		fmt.Fprintf(os.Stderr, "cannot read %s: %s\n", manFile, pathErr.Err)
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot show %s\n", err)
+		os.Exit(1)
+	}
+	if debugEnabled {
+		dumpAst(page)
 	}
 
-	parser := parser{}
-	page := parser.parseMdoc(data)
-	page.mergeSpans()
-	dumpAst(page)
+	if *strict {
+		if reportWarnings(os.Stderr, manFile, page) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if output != "" {
+		if err := writePage(page, output); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot write %s: %s\n", output, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Println(manFile)
+
+	model := NewModel(page)
+	keys, searchKeys := newKeyMap(), defaultSearchKeyMap()
+	if err := loadKeyConfig(keyConfigPath(), &keys, &searchKeys); err != nil {
+		model.message = fmt.Sprintf("Ignoring %s: %s", keyConfigPath(), err)
+	}
+	model.setKeys(keys, searchKeys)
+	model.historyPath = searchHistoryPath()
+	model.history = loadSearchHistory(model.historyPath)
 
 	p := tea.NewProgram(
-		NewModel(page),
-		tea.WithAltScreen(),       // use the full size of the terminal in its "alternate screen buffer"
-		tea.WithMouseCellMotion(), // turn on mouse support so we can track the mouse wheel
+		model,
+		tea.WithAltScreen(),      // use the full size of the terminal in its "alternate screen buffer"
+		tea.WithMouseAllMotion(), // turn on mouse support so we can track the mouse wheel and hover over links
 	)
 
 	if _, err := p.Run(); err != nil {
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/benwaffle/doc/mandoc"
+	"github.com/mattn/go-runewidth"
+)
+
+func parse(t *testing.T, doc string) mandoc.Page {
+	page, err := mandoc.Parse(strings.NewReader(doc), mandoc.FormatAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *page
+}
+
+func TestLocaleNames(t *testing.T) {
+	tests := []struct {
+		lang  string
+		names []string
+	}{
+		{"", nil},
+		{"C", nil},
+		{"C.UTF-8", nil},
+		{"fr", []string{"fr"}},
+		{"fr_FR", []string{"fr_FR", "fr"}},
+		{"fr_FR.UTF-8", []string{"fr_FR.UTF-8", "fr_FR", "fr"}},
+		{"de_DE.ISO8859-1@euro", []string{"de_DE.ISO8859-1", "de_DE", "de"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.lang, func(t *testing.T) {
+			t.Setenv("LC_ALL", "")
+			t.Setenv("LC_MESSAGES", "")
+			t.Setenv("LANG", test.lang)
+			names := localeNames()
+			if !slices.Equal(names, test.names) {
+				t.Errorf("localeNames() with LANG=%q = %v, wanted %v", test.lang, names, test.names)
+			}
+		})
+	}
+}
+
+func TestSortBySection(t *testing.T) {
+	paths := []string{
+		"/usr/share/man/man3/printf.3.gz",
+		"/usr/share/man/man3p/printf.3p.gz",
+		"/usr/share/man/man1/printf.1.gz",
+		"/usr/share/man/mann/printf.n.gz",
+	}
+
+	t.Setenv("MANSECT", "")
+	sortBySection(paths)
+	expected := []string{
+		"/usr/share/man/man1/printf.1.gz",
+		"/usr/share/man/man3/printf.3.gz",
+		"/usr/share/man/man3p/printf.3p.gz",
+		"/usr/share/man/mann/printf.n.gz",
+	}
+	if !slices.Equal(paths, expected) {
+		t.Errorf("default order gave %v, wanted %v", paths, expected)
+	}
+
+	t.Setenv("MANSECT", "3p:3:1")
+	sortBySection(paths)
+	expected = []string{
+		"/usr/share/man/man3p/printf.3p.gz",
+		"/usr/share/man/man3/printf.3.gz",
+		"/usr/share/man/man1/printf.1.gz",
+		"/usr/share/man/mann/printf.n.gz",
+	}
+	if !slices.Equal(paths, expected) {
+		t.Errorf("MANSECT=3p:3:1 gave %v, wanted %v", paths, expected)
+	}
+}
+
+func TestFindDocFollowsSymlinks(t *testing.T) {
+	mandir := t.TempDir()
+	section := filepath.Join(mandir, "man1")
+	if err := os.Mkdir(section, 0755); err != nil {
+		t.Fatal(err)
+	}
+	gzip := filepath.Join(section, "gzip.1")
+	if err := os.WriteFile(gzip, []byte(".TH GZIP 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("gzip.1", filepath.Join(section, "gunzip.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("missing.1", filepath.Join(section, "zcat.1")); err != nil {
+		t.Fatal(err)
+	}
+
+	gzip, _ = filepath.EvalSymlinks(gzip) // the temp dir may itself be a symlink
+	if path := findDocInManSection(section, "gunzip"); path != gzip {
+		t.Errorf("gunzip resolved to %q, wanted %q", path, gzip)
+	}
+	if path := findDocInManSection(section, "zcat"); path != "" {
+		t.Errorf("dangling zcat resolved to %q, wanted no match", path)
+	}
+}
+
+func TestLoadManPageWithoutTitle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "frob.3p")
+	if err := os.WriteFile(path, []byte(".Sh NAME\nfrob \\- frobnicate\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := loadManPage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Name != "frob" || page.Section != "3p" {
+		t.Errorf("title = %s(%s), wanted frob(3p)", page.Name, page.Section)
+	}
+}
+
+func TestWritePage(t *testing.T) {
+	page := parse(t, ".Dd July 4, 2020\n.Sh NAME\n.Nm frob\n.Nd frobnicate")
+	path := filepath.Join(t.TempDir(), "frob.txt")
+	if err := writePage(page, path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := string(data); !strings.Contains(text, "frob – frobnicate") || strings.Contains(text, "\x1b") {
+		t.Errorf("wrote %q", text)
+	}
+
+	if err := writePage(page, filepath.Join(t.TempDir(), "missing", "frob.txt")); err == nil {
+		t.Error("expected an error writing to a missing directory")
+	}
+}
+
+func TestWritePageWidth(t *testing.T) {
+	defer func(width int) { pageWidth = width }(pageWidth)
+	page := parse(t, ".Sh DESCRIPTION\n"+strings.Repeat("word ", 40))
+	for _, width := range []int{0, 30} {
+		pageWidth = width
+		expected := outputWidth()
+		for _, line := range strings.Split(renderPlain(page, outputWidth()), "\n") {
+			if w := runewidth.StringWidth(line); w > expected {
+				t.Errorf("-width %d wrote a line %d wide: %q", width, w, line)
+			}
+		}
+	}
+}
+
+func TestReportWarnings(t *testing.T) {
+	page := parse(t, ".Sh NAME\n.Zz foo\ntext\n.Yy bar\n\\*(lqquoted\n.El")
+
+	var out strings.Builder
+	if !reportWarnings(&out, "frob.1", page) {
+		t.Error("expected warnings")
+	}
+	if expected := "frob.1:2: unknown macro .Zz\nfrob.1:4: unknown macro .Yy\nfrob.1:5: shown as plain text: \\*(lq\nfrob.1:6: misplaced .El ignored\n"; out.String() != expected {
+		t.Errorf("reported %q, wanted %q", out.String(), expected)
+	}
+
+	page = parse(t, ".Sh NAME\ntext")
+	if reportWarnings(&out, "frob.1", page) {
+		t.Error("expected no warnings")
+	}
+}
+
+func TestManDirs(t *testing.T) {
+	envDir, systemDir := t.TempDir(), t.TempDir()
+	defer func(command []string) { manpathCommand = command }(manpathCommand)
+	manpathCommand = []string{"echo", systemDir}
+
+	t.Setenv("MANPATH", envDir+":/nonexistent")
+	if dirs := manDirs(); !slices.Equal(dirs, []string{envDir}) {
+		t.Errorf("with MANPATH set got %q, wanted only %q", dirs, envDir)
+	}
+
+	t.Setenv("MANPATH", "")
+	if dirs := manDirs(); !slices.Equal(dirs, []string{systemDir}) {
+		t.Errorf("with MANPATH empty got %q, wanted %q from manpath", dirs, systemDir)
+	}
+}
+
+func TestDecodeManPage(t *testing.T) {
+	tests := []struct {
+		path     string
+		data     []byte
+		expected string
+	}{
+		{"/usr/share/man/man1/ls.1", []byte("caf\xc3\xa9"), "café"},
+		{"/usr/share/man/fr/man1/ls.1", []byte("caf\xe9"), "café"},
+		{"/usr/share/man/fr.ISO8859-1/man1/ls.1", []byte("caf\xe9"), "café"},
+		{"/usr/share/man/ru.KOI8-R/man1/ls.1", []byte("\xd3\xd0\xc9\xd3\xcf\xcb"), "список"},
+		{"/usr/share/man/pl.ISO8859-2/man1/ls.1", []byte("\xb3\xf3d\xbc"), "łódź"},
+		{"/usr/share/man/xx.bogus/man1/ls.1", []byte("caf\xe9"), "café"},
+		// a character split by the end of what's looked at is still UTF-8
+		{"/usr/share/man/man1/ls.1", []byte(strings.Repeat("a", pagePeek-1) + "é"), strings.Repeat("a", pagePeek-1) + "é"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			reader, err := decodeManPage(test.path, bytes.NewReader(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res, err := io.ReadAll(reader); err != nil || string(res) != test.expected {
+				t.Errorf("decoded %q, %v, wanted %q", res, err, test.expected)
+			}
+		})
+	}
+}
+
+// Not a test: runs main with the arguments runDoc passes, in a child process
+// so it can exit.
+func TestHelperProcess(t *testing.T) {
+	args, ok := os.LookupEnv("DOC_HELPER_ARGS")
+	if !ok {
+		return
+	}
+	os.Args = append([]string{"doc"}, strings.Split(args, "\n")...)
+	main()
+	os.Exit(0)
+}
+
+// Run doc with args and the extra environment env, returning its exit status
+// and what it wrote to stdout and stderr.
+func runDoc(t *testing.T, env []string, args ...string) (int, string, string) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Env = append(cmd.Env, "DOC_HELPER_ARGS="+strings.Join(args, "\n"))
+	var stdout, stderr strings.Builder
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), stdout.String(), stderr.String()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return 0, stdout.String(), stderr.String()
+}
+
+func TestVersionAndHelp(t *testing.T) {
+	status, stdout, _ := runDoc(t, nil, "-version")
+	if status != 0 || stdout != version+"\n" {
+		t.Errorf("-version exited %d printing %q, wanted 0 and %q", status, stdout, version+"\n")
+	}
+
+	status, _, stderr := runDoc(t, nil, "-help")
+	if status != 0 {
+		t.Errorf("-help exited %d", status)
+	}
+	for _, expected := range []string{"Usage: doc [flags] [section] <command | file>", "-version", "print the version and exit"} {
+		if !strings.Contains(stderr, expected) {
+			t.Errorf("-help printed %q, wanted it to mention %q", stderr, expected)
+		}
+	}
+
+	status, _, stderr = runDoc(t, nil, "1", "ls", "extra")
+	if status != 1 || !strings.HasPrefix(stderr, "Usage: doc") {
+		t.Errorf("too many arguments exited %d printing %q, wanted 1 and the usage", status, stderr)
+	}
+
+	status, _, stderr = runDoc(t, nil, "-no-such-flag")
+	if status != 2 || !strings.Contains(stderr, "flag provided but not defined: -no-such-flag") {
+		t.Errorf("an unknown flag exited %d printing %q", status, stderr)
+	}
+}
+
+func TestSeveralMatchesWithoutChooser(t *testing.T) {
+	mandir := t.TempDir()
+	var paths []string
+	for _, section := range []string{"1", "3"} {
+		path := filepath.Join(mandir, "man"+section, "frob."+section)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(".Sh NAME\ntext"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	for _, args := range [][]string{{"-o", "-"}, {"-strict"}, {"-raw"}} {
+		status, _, stderr := runDoc(t, []string{"MANPATH=" + mandir}, append(args, "frob")...)
+		if status != 1 {
+			t.Errorf("%v exited with %d, wanted 1", args, status)
+		}
+		expected := "several pages match \"frob\", pick one with a section or a path:\n" +
+			"  frob(1)  " + paths[0] + "\n" +
+			"  frob(3)  " + paths[1] + "\n"
+		if stderr != expected {
+			t.Errorf("%v reported %q, wanted %q", args, stderr, expected)
+		}
+	}
+}
+
+func TestStrictExitStatus(t *testing.T) {
+	tests := []struct {
+		doc    string
+		status int
+		output string
+	}{
+		{".Sh NAME\ntext", 0, ""},
+		{".Sh NAME\ntext\n\\*(lqquoted", 1, "frob.1:3: shown as plain text: \\*(lq\n"},
+		{".Sh NAME\n'br\ntext", 1, "frob.1:2: shown as plain text: 'br\n"},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "frob.1")
+		if err := os.WriteFile(path, []byte(test.doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		status, _, stderr := runDoc(t, nil, "-strict", path)
+		if status != test.status {
+			t.Errorf("%q exited with %d, wanted %d", test.doc, status, test.status)
+		}
+		if expected := strings.ReplaceAll(test.output, "frob.1", path); stderr != expected {
+			t.Errorf("%q reported %q, wanted %q", test.doc, stderr, expected)
+		}
+	}
+}
+
+func TestUTF8Locale(t *testing.T) {
+	tests := []struct {
+		all, ctype, lang string
+		expected         bool
+	}{
+		{"", "", "", true},
+		{"", "", "en_US.UTF-8", true},
+		{"", "", "C", false},
+		{"C", "", "en_US.UTF-8", false},
+		{"", "de_DE.utf8", "C", true},
+	}
+	for _, test := range tests {
+		t.Setenv("LC_ALL", test.all)
+		t.Setenv("LC_CTYPE", test.ctype)
+		t.Setenv("LANG", test.lang)
+		if res := utf8Locale(); res != test.expected {
+			t.Errorf("utf8Locale() with %+v = %v", test, res)
+		}
+	}
+}
+
+func TestTerminalHyperlinks(t *testing.T) {
+	tests := []struct {
+		env      map[string]string
+		expected bool
+	}{
+		{map[string]string{}, false},
+		{map[string]string{"TERM": "xterm-256color"}, false},
+		{map[string]string{"TERM_PROGRAM": "iTerm.app"}, true},
+		{map[string]string{"VTE_VERSION": "6800"}, true},
+		{map[string]string{"VTE_VERSION": "4200"}, false},
+		{map[string]string{"KITTY_WINDOW_ID": "1"}, true},
+		{map[string]string{"TERM": "foot-extra"}, true},
+	}
+	for _, test := range tests {
+		for _, name := range []string{"TERM_PROGRAM", "VTE_VERSION", "KITTY_WINDOW_ID", "WT_SESSION", "KONSOLE_VERSION", "TERM"} {
+			t.Setenv(name, test.env[name])
+		}
+		if res := terminalHyperlinks(); res != test.expected {
+			t.Errorf("terminalHyperlinks() with %v = %v", test.env, res)
+		}
+	}
+}
+
+func TestLoadManPageFallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "frob.1")
+	if err := os.WriteFile(path, []byte(".Dt FROB 1\n.Sh NAME\n.Bl -tag \"frob\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer func(renderers [][]string) { externalRenderers = renderers }(externalRenderers)
+
+	externalRenderers = [][]string{{"doc-no-such-command"}, {"printf", "NAME\n       frob - frobnicate\n"}}
+	page, err := loadManPage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Name != "frob" || len(page.Sections) != 1 || page.Sections[0].Name != "NAME" {
+		t.Errorf("formatted page = %+v", page)
+	}
+
+	externalRenderers = [][]string{{"doc-no-such-command"}}
+	if _, err := loadManPage(path); err == nil || errors.Is(err, errNoRenderer) {
+		t.Errorf("error = %v, wanted the parse error", err)
+	}
+}
+
+func TestPreprocessors(t *testing.T) {
+	tests := map[string]string{
+		"'\\\" t\n.TH GREP 1":     "t",
+		".\\\" te\n.TH EQN 1":     "te",
+		"'\\\" tr\n.TH GREP 1":    "tr",
+		".\\\" this is a comment": "",
+		".TH LS 1":                "",
+	}
+	for page, expected := range tests {
+		if res := preprocessors(page); res != expected {
+			t.Errorf("preprocessors(%q) = %q, wanted %q", page, res, expected)
+		}
+	}
+}
+
+func TestLoadManPageReadError(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := loadManPage(filepath.Join(dir, "missing.1")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing page gave %v, wanted a not exist error", err)
+	}
+	var pathErr *fs.PathError
+	if _, err := loadManPage(dir); !errors.As(err, &pathErr) {
+		t.Errorf("directory gave %v, wanted a read error", err)
+	}
+}
+
+func TestResolveSource(t *testing.T) {
+	mandir := t.TempDir()
+	for _, dir := range []string{"man1", "man8"} {
+		if err := os.Mkdir(filepath.Join(mandir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gzip := filepath.Join(mandir, "man1", "gzip.1")
+	stub := filepath.Join(mandir, "man1", "gunzip.1")
+	loop := filepath.Join(mandir, "man8", "loop.8")
+	broken := filepath.Join(mandir, "man1", "broken.1")
+	files := map[string]string{
+		gzip:   ".TH GZIP 1\n",
+		stub:   ".\\\" an alias\n.so man1/gzip.1\n",
+		loop:   ".so man8/loop.8\n",
+		broken: ".so man1/missing.1\n",
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resolve := func(path string) (string, string, error) {
+		source, err := openManPage(path)
+		if err != nil {
+			return "", "", err
+		}
+		if path, source, err = resolveSource(path, source); err != nil {
+			return "", "", err
+		}
+		defer source.Close()
+		data, err := io.ReadAll(source)
+		return path, string(data), err
+	}
+
+	path, data, err := resolve(stub)
+	if err != nil || path != gzip || data != files[gzip] {
+		t.Errorf("resolveSource(gunzip.1) = %q, %q, %v", path, data, err)
+	}
+	if path, _, err := resolve(gzip); err != nil || path != gzip {
+		t.Errorf("resolveSource(gzip.1) = %q, %v", path, err)
+	}
+	if _, _, err := resolve(loop); err == nil {
+		t.Error("expected an error for a .so loop")
+	}
+	if _, _, err := resolve(broken); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing include gave %v", err)
+	}
+
+	page, err := loadManPage(stub)
+	if err != nil || page.Name != "GZIP" {
+		t.Errorf("loadManPage(gunzip.1) = %+v, %v", page, err)
+	}
+}
@@ -0,0 +1,172 @@
+package mandoc
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// Cat pages are man pages that have already been formatted, with bold and
+// underlined text made by overstriking characters with backspaces.
+
+// A page is preformatted if it has no roff requests, or if it uses overstrike
+// and has no title macro. Source pages sometimes overstrike a word or two
+// themselves.
+func isCatPage(doc string) bool {
+	requests, title := false, false
+	for _, line := range strings.Split(doc, "\n") {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			requests = true
+			switch macro, _ := nextToken(line[1:]); macro {
+			case "TH", "Dd", "Dt":
+				title = true
+			}
+		}
+	}
+	return !requests || (strings.Contains(doc, "\b") && !title)
+}
+
+// Cat pages are formatted with tab stops every 8 columns, whatever tab width
+// the page is shown with.
+const catTabWidth = 8
+
+// Split a line of overstruck text into styled spans: c\bc is bold and _\bc is
+// underlined. Tabs are expanded, since the page is already laid out.
+func parseOverstrike(line string) []Span {
+	var res []Span
+	current := TextSpan{Typ: TagPlain, NoSpace: true}
+	col := 0
+	add := func(typ TextTag, c rune) {
+		if typ != current.Typ && current.Text != "" {
+			res = append(res, current)
+			current = TextSpan{NoSpace: true}
+		}
+		current.Typ = typ
+		if c == '\t' {
+			spaces := catTabWidth - col%catTabWidth
+			current.Text += strings.Repeat(" ", spaces)
+			col += spaces
+			return
+		}
+		current.Text += string(c)
+		col += runewidth.RuneWidth(c)
+	}
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		if i+2 >= len(runes) || runes[i+1] != '\b' {
+			add(TagPlain, runes[i])
+			continue
+		}
+
+		first, second := runes[i], runes[i+2]
+		switch {
+		case first == '_' && second != '_':
+			add(TagUnderline, second)
+		case first == second:
+			add(TagBold, second)
+		default: // other overstrikes, like +\bo for a bullet
+			add(TagPlain, second)
+		}
+		i += 2
+		for i+2 < len(runes) && runes[i+1] == '\b' { // struck more than twice
+			i += 2
+		}
+	}
+	if current.Text != "" {
+		res = append(res, current)
+	}
+	return res
+}
+
+var overstrike = regexp.MustCompile(".\b")
+
+// The header and footer look like "LS(1)   User Commands   LS(1)".
+var catTitle = regexp.MustCompile(`^(\S+)\((\w+)\)`)
+var catFooter = regexp.MustCompile(`\S+\(\w+\)$`)
+
+func parseCatPage(doc string) Page {
+	page := Page{}
+	var currentSection *Section
+
+	lines := strings.Split(strings.ReplaceAll(doc, "\r\n", "\n"), "\n")
+	for _, line := range lines {
+		plain := overstrike.ReplaceAllString(line, "")
+
+		switch {
+		case strings.TrimSpace(plain) == "":
+			if currentSection != nil {
+				currentSection.Contents = append(currentSection.Contents, TextSpan{TagPlain, "\n", true})
+			}
+
+		case !strings.HasPrefix(plain, " ") && catFooter.MatchString(strings.TrimSpace(plain)): // header or footer
+			if parts := catTitle.FindStringSubmatch(plain); parts != nil && page.Name == "" {
+				page.Name = parts[1]
+				page.Section = parts[2]
+			}
+
+		case !strings.HasPrefix(plain, " ") && !strings.HasPrefix(plain, "\t"): // section header
+			if currentSection != nil {
+				page.Sections = append(page.Sections, *currentSection)
+			}
+			currentSection = &Section{Name: strings.TrimSpace(plain)}
+
+		case currentSection != nil:
+			currentSection.Contents = append(currentSection.Contents, parseOverstrike(line)...)
+			currentSection.Contents = append(currentSection.Contents, TextSpan{TagPlain, "\n", true})
+		}
+	}
+	if currentSection != nil {
+		page.Sections = append(page.Sections, *currentSection)
+	}
+
+	for i := range page.Sections {
+		trimTrailingLines(&page.Sections[i])
+		dedent(&page.Sections[i])
+	}
+	return page
+}
+
+// Remove the line breaks at the end of a section, left by the blank lines
+// before the next section header or the footer.
+func trimTrailingLines(s *Section) {
+	for len(s.Contents) > 0 && s.Contents[len(s.Contents)-1] == (TextSpan{TagPlain, "\n", true}) {
+		s.Contents = s.Contents[:len(s.Contents)-1]
+	}
+}
+
+// Remove the indentation common to every line of a section, since cat pages
+// indent the body under each section header.
+func dedent(s *Section) {
+	indent := -1
+	atLineStart := true
+	for _, span := range s.Contents {
+		ts := span.(TextSpan)
+		if ts.Text == "\n" {
+			atLineStart = true
+			continue
+		}
+		if atLineStart {
+			n := len(ts.Text) - len(strings.TrimLeft(ts.Text, " "))
+			if indent == -1 || n < indent {
+				indent = n
+			}
+		}
+		atLineStart = false
+	}
+
+	atLineStart = true
+	for i, span := range s.Contents {
+		ts := span.(TextSpan)
+		if ts.Text == "\n" {
+			atLineStart = true
+			continue
+		}
+		if atLineStart && indent > 0 {
+			ts.Text = ts.Text[min(indent, len(ts.Text)):]
+			s.Contents[i] = ts
+		}
+		atLineStart = false
+	}
+}
@@ -0,0 +1,58 @@
+package mandoc
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestParseOverstrike(t *testing.T) {
+	spans := parseOverstrike("use l\bls\bs -\b-a\ba _\bf_\bi_\bl_\be")
+	expected := []Span{
+		TextSpan{TagPlain, "use ", true},
+		TextSpan{TagBold, "ls", true},
+		TextSpan{TagPlain, " ", true},
+		TextSpan{TagBold, "-a", true},
+		TextSpan{TagPlain, " ", true},
+		TextSpan{TagUnderline, "file", true},
+	}
+	if !slices.Equal(spans, expected) {
+		t.Errorf("%+v did not equal %+v", spans, expected)
+	}
+}
+
+func TestCatPageTabs(t *testing.T) {
+	spans := parseOverstrike("-\b-a\ba\tall\tfiles")
+	expected := []Span{
+		TextSpan{TagBold, "-a", true},
+		TextSpan{TagPlain, "      all     files", true},
+	}
+	if !slices.Equal(spans, expected) {
+		t.Errorf("%+v did not equal %+v", spans, expected)
+	}
+}
+
+func TestParseCatPage(t *testing.T) {
+	doc := "LS(1)          User Commands          LS(1)\n" +
+		"\n" +
+		"N\bNA\bAM\bME\bE\n" +
+		"       ls - list directory contents\n" +
+		"\n" +
+		"GNU coreutils 9.1     September 2022     LS(1)\n"
+	if !isCatPage(doc) {
+		t.Fatal("overstruck page wasn't detected as a cat page")
+	}
+
+	page := parseCatPage(doc)
+	page.mergeSpans()
+	if page.Name != "LS" || page.Section != "1" {
+		t.Errorf("title parsed as %s(%s)", page.Name, page.Section)
+	}
+	expected := []Section{{
+		Name:     "NAME",
+		Contents: []Span{TextSpan{TagPlain, "ls - list directory contents", true}},
+	}}
+	if !reflect.DeepEqual(page.Sections, expected) {
+		t.Errorf("%+v did not equal %+v", page.Sections, expected)
+	}
+}
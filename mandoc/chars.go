@@ -0,0 +1,106 @@
+package mandoc
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Special characters named by \(xx and \[name] escapes, from groff_char(7).
+// Accented letters are built from accentMarks instead.
+var specialChars = map[string]string{
+	// dashes, quotes, and punctuation
+	"em": "—", "en": "–", "hy": "‐", "-": "-",
+	"lq": "“", "rq": "”", "oq": "‘", "cq": "’", "dq": `"`, "aq": "'",
+	"Bq": "„", "bq": "‚", "Fo": "«", "Fc": "»", "fo": "‹", "fc": "›",
+	"r!": "¡", "r?": "¿", "bu": "•", "ci": "○", "sq": "□", "ba": "|", "br": "│",
+	"ul": "_", "rn": "‾", "rs": `\`, "sl": "/", "ha": "^", "ti": "~",
+	"aa": "´", "ga": "`", "ad": "¨", "ac": "¸", "a-": "¯", "ao": "˚",
+	"sc": "§", "ps": "¶", "dg": "†", "dd": "‡", "lh": "☜", "rh": "☞",
+	"co": "©", "rg": "®", "tm": "™", "de": "°", "%0": "‰", "fm": "′", "sd": "″",
+	"at": "@", "sh": "#", "lB": "[", "rB": "]", "lC": "{", "rC": "}",
+	"la": "⟨", "ra": "⟩", "OK": "✓",
+
+	// currency
+	"Do": "$", "ct": "¢", "Eu": "€", "eu": "€", "Ye": "¥", "Po": "£", "Cs": "¤",
+
+	// arrows
+	"->": "→", "<-": "←", "<>": "↔", "ua": "↑", "da": "↓", "va": "↕",
+	"=>": "⇒", "<=>": "⇔", "lA": "⇐", "rA": "⇒", "hA": "⇔", "uA": "⇑", "dA": "⇓",
+
+	// mathematics
+	"+-": "±", "mu": "×", "di": "÷", "pl": "+", "mi": "−", "eq": "=",
+	"<=": "≤", ">=": "≥", "!=": "≠", "==": "≡", "=~": "≅", "~~": "≈", "ap": "∼",
+	"no": "¬", "AN": "∧", "OR": "∨", "fa": "∀", "te": "∃", "mo": "∈", "nm": "∉",
+	"sb": "⊂", "sp": "⊃", "ib": "⊆", "ip": "⊇", "ca": "∩", "cu": "∪",
+	"if": "∞", "pd": "∂", "gr": "∇", "is": "∫", "sr": "√", "pt": "∝", "es": "∅",
+	"**": "∗", "pc": "·", "f/": "⁄", "12": "½", "14": "¼", "34": "¾",
+	"S1": "¹", "S2": "²", "S3": "³", "md": "⋅", "tf": "∴",
+
+	// letters without a combining accent
+	"ss": "ß", "AE": "Æ", "ae": "æ", "OE": "Œ", "oe": "œ", "/O": "Ø", "/o": "ø",
+	"-D": "Đ", "Sd": "ð", "TP": "Þ", "Tp": "þ", ".i": "ı", "/L": "Ł", "/l": "ł",
+	"ff": "ff", "fi": "fi", "fl": "fl", "Fi": "ffi", "Fl": "ffl",
+
+	// Greek
+	"*A": "Α", "*B": "Β", "*G": "Γ", "*D": "Δ", "*E": "Ε", "*Z": "Ζ", "*Y": "Η",
+	"*H": "Θ", "*I": "Ι", "*K": "Κ", "*L": "Λ", "*M": "Μ", "*N": "Ν", "*C": "Ξ",
+	"*O": "Ο", "*P": "Π", "*R": "Ρ", "*S": "Σ", "*T": "Τ", "*U": "Υ", "*F": "Φ",
+	"*X": "Χ", "*Q": "Ψ", "*W": "Ω",
+	"*a": "α", "*b": "β", "*g": "γ", "*d": "δ", "*e": "ε", "*z": "ζ", "*y": "η",
+	"*h": "θ", "*i": "ι", "*k": "κ", "*l": "λ", "*m": "μ", "*n": "ν", "*c": "ξ",
+	"*o": "ο", "*p": "π", "*r": "ρ", "ts": "ς", "*s": "σ", "*t": "τ", "*u": "υ",
+	"*f": "φ", "*x": "χ", "*q": "ψ", "*w": "ω",
+}
+
+// Combining marks for accented letters, named by the accent and the letter,
+// e.g. 'e for é and :u for ü.
+var accentMarks = map[byte]string{
+	'\'': "́", // acute
+	'`':  "̀", // grave
+	'^':  "̂", // circumflex
+	':':  "̈", // diaeresis
+	'~':  "̃", // tilde
+	',':  "̧", // cedilla
+	'o':  "̊", // ring
+	'v':  "̌", // caron
+}
+
+// The character a special character escape names, e.g. — for em. Besides
+// the names in specialChars, there are accented letters and Unicode code
+// points like u00E9.
+func specialChar(name string) (string, bool) {
+	if c, ok := specialChars[name]; ok {
+		return c, true
+	}
+	if len(name) == 2 {
+		if mark, ok := accentMarks[name[0]]; ok && isLetter(name[1]) {
+			return norm.NFC.String(name[1:] + mark), true
+		}
+	}
+	if len(name) >= 5 && name[0] == 'u' {
+		if code, err := strconv.ParseUint(name[1:], 16, 32); err == nil {
+			return string(rune(code)), true
+		}
+	}
+	return "", false
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// Length of the special character escape at the start of s, \(xx or
+// \[name], and the name it contains. Returns 0 if s doesn't start with one.
+func specialCharEscape(s string) (int, string) {
+	switch {
+	case strings.HasPrefix(s, `\(`) && len(s) >= 4:
+		return 4, s[2:4]
+	case strings.HasPrefix(s, `\[`):
+		if end := strings.IndexByte(s, ']'); end != -1 {
+			return end + 1, s[2:end]
+		}
+	}
+	return 0, ""
+}
@@ -0,0 +1,1641 @@
+package mandoc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/shlex"
+)
+
+type Page struct {
+	Name     string
+	Section  string // like 1, 3p, or n
+	Date     string
+	OS       string // operating system, shown in the footer
+	Source   string // like "GNU coreutils 9.4", shown in the footer of man pages
+	Manual   string // like "User Commands", shown at the top
+	Arch     string // the machine architecture an mdoc page is about, if any
+	Sections []Section
+	Warnings []Warning
+}
+
+// A Warning records a macro the parser doesn't understand or ignores, or
+// source it shows as plain text, so gaps in the rendering can be traced back
+// to it.
+type Warning struct {
+	Line      int
+	Macro     string // an unknown macro, without the dot
+	Raw       string // or source shown as is, like an escape or a ' request
+	Misplaced string // or a macro ignored where it means nothing, like .El without a list
+}
+
+type Section struct {
+	Name     string
+	Contents []Span
+	Lines    []int // source line of each span in Contents
+}
+
+// Whether this is the standard section name, like SYNOPSIS, whatever its case.
+func (section *Section) is(name string) bool {
+	return section != nil && strings.EqualFold(section.Name, name)
+}
+
+type TextTag int
+
+const (
+	TagPlain TextTag = iota
+	TagNameRef
+	TagArg
+	TagEnvVar
+	TagVariable
+	TagPath
+	TagSubsectionHeader
+	TagLiteral
+	TagSymbolic
+	TagBold
+	TagItalic
+	TagUnderline
+	TagSingleQuote
+	TagDoubleQuote
+	TagTableCellSeparator
+	TagConfig
+	TagConstant
+	TagType
+	TagBoldItalic
+	TagFunction
+	TagDescriptionDash // the dash after the names in NAME, which has no text of its own
+)
+
+type TextSpan struct {
+	Typ     TextTag
+	Text    string
+	NoSpace bool // Set to false by default
+}
+
+type DecorationTag int
+
+const (
+	DecorationNone DecorationTag = iota
+	DecorationOptional
+	DecorationParens
+	DecorationSingleQuote
+	DecorationDoubleQuote
+	DecorationQuotedLiteral
+	DecorationBraces
+)
+
+type DecoratedSpan struct {
+	Typ      DecorationTag
+	Contents []Span
+	NoSpace  bool // Set to false by default
+}
+
+// Opens or closes a decoration with a macro pair like .Oo and .Oc, which can be
+// on different lines. Markers are replaced by a DecoratedSpan once the
+// decoration is closed.
+type decorationMarker struct {
+	Typ     DecorationTag
+	Open    bool
+	NoSpace bool // no space after the decoration this closes
+}
+
+// Macros that open and close decorations.
+var decorationMarkers = map[string]decorationMarker{
+	"Oo":  {DecorationOptional, true, false},
+	"Oc":  {DecorationOptional, false, false},
+	"Po":  {DecorationParens, true, false},
+	"Pc":  {DecorationParens, false, false},
+	"So":  {DecorationSingleQuote, true, false},
+	"Sc":  {DecorationSingleQuote, false, false},
+	"Do":  {DecorationDoubleQuote, true, false},
+	"Dc":  {DecorationDoubleQuote, false, false},
+	"Bro": {DecorationBraces, true, false},
+	"Brc": {DecorationBraces, false, false},
+}
+
+// Replace pairs of decoration markers with a DecoratedSpan of the spans between
+// them. Decorations left open are closed at the end.
+func foldDecorations(spans []Span) []Span {
+	var res []Span
+	open := stack[*DecoratedSpan]{}
+	add := func(span Span) {
+		if open.Len() > 0 {
+			open.Peek().Contents = append(open.Peek().Contents, span)
+		} else {
+			res = append(res, span)
+		}
+	}
+	for _, span := range spans {
+		marker, ok := span.(decorationMarker)
+		switch {
+		case !ok:
+			add(span)
+		case marker.Open:
+			open.Push(&DecoratedSpan{Typ: marker.Typ})
+		case open.Len() > 0:
+			closed := open.Pop()
+			closed.NoSpace = marker.NoSpace
+			add(*closed)
+		}
+	}
+	for open.Len() > 0 {
+		add(*open.Pop())
+	}
+	return res
+}
+
+// A hyperlink from .UR or a mail address from .MT. Without any text, the
+// address itself is shown.
+type LinkSpan struct {
+	URL      string
+	Contents []Span
+	NoSpace  bool
+}
+
+// A line of literal text, from .Dl, shown indented and never wrapped
+type LiteralLine struct {
+	Contents []Span
+}
+
+// A line of a .Bd -centered display, centered in the width it's rendered in
+type CenteredLine struct {
+	Contents []Span
+}
+
+// A line of a .Bd -literal or -unfilled display, kept as it is in the source
+// instead of being filled and wrapped
+type UnfilledLine struct {
+	Contents []Span
+	Indent   int  // in tab widths, from -offset indent or indent-two
+	Literal  bool // shown in the literal style
+}
+
+type FlagSpan struct {
+	Flag    string
+	Dash    bool
+	NoSpace bool // Set to false by default
+}
+
+type ManRef struct {
+	Name    string
+	Section string // empty if the reference has no section
+	NoSpace bool
+}
+
+type StandardRef struct {
+	Standard string
+	NoSpace  bool
+}
+
+type LibraryRef struct {
+	Library string
+	NoSpace bool
+}
+
+type ListType int
+
+const (
+	BulletList ListType = iota // Bullet item list
+	DashList                   // Hyphenated list
+	ItemList                   // Unlabeled list
+	EnumList                   // Enumerated list
+	TagList                    // Tag labeled list
+	DiagList                   // Diagnostic list
+	HangList                   // Hanging labeled list
+	OhangList                  // Overhanging labeled list
+	InsetList                  // Inset or run-on labeled list
+	ColumnList                 // Columnar list (table)
+)
+
+type List struct {
+	Typ     ListType
+	Items   []ListItem
+	Compact bool
+	Width   int
+	Columns []string
+	Indent  int
+	Depth   int // how many enumerated lists this one is nested in
+}
+
+type ListItem struct {
+	Tag      []Span
+	Contents []Span
+	Lines    []int // source line of each span in Contents
+}
+
+// Running text filled differently from the rest of the page: wrapped to a
+// line length set with .ll rather than the width of the window, or justified
+// by .ad. It starts on a new line.
+type Block struct {
+	Contents   []Span
+	Lines      []int // source line of each span in Contents
+	LineLength int   // columns to wrap to, or 0 for the full width
+	Justify    bool  // fill lines to the full width
+}
+
+type font int
+
+const (
+	fontPlain font = iota // Roman
+	fontBold
+	fontItalic
+	fontBoldItalic
+	fontLiteral // Constant width
+)
+
+type parser struct {
+	format      Format // the macro set to understand, or FormatAuto for both
+	lastFont    font
+	currentFont font
+	registers   map[string]int      // number registers set with .nr
+	macros      map[string][]string // user-defined macros from .de and .am
+	justify     bool                // text is adjusted to both margins
+	compact     bool                // no blank line before .IP and .TP items, from .PD 0
+	lineLength  int                 // columns to fill text to from .ll, or 0 for the full width
+	lastLength  int                 // the line length before the last .ll, restored by a bare .ll
+}
+
+// The space before a man page list item, matching the blank line that .Bl
+// lists have unless they're -compact.
+func (p *parser) itemGap() string {
+	if p.compact {
+		return "\n"
+	}
+	return "\n\n"
+}
+
+func parseError(line int, info string, err error) error {
+	return fmt.Errorf("Error parsing %s on line %d: %w", info, line, err)
+}
+
+// Merge adjacent spans if possible. This makes ast.json much easier to read.
+func (page *Page) mergeSpans() {
+	for i, section := range page.Sections {
+		hasLines := len(section.Lines) == len(section.Contents)
+
+		var contents []Span
+		var lines []int
+		var merged *TextSpan = nil
+		mergedLine := 0
+		emit := func(span Span, line int) {
+			contents = append(contents, span)
+			if hasLines {
+				lines = append(lines, line)
+			}
+		}
+		for j, span := range section.Contents {
+			line := 0
+			if hasLines {
+				line = section.Lines[j]
+			}
+
+			if merged == nil { // new range
+				if ts, ok := span.(TextSpan); ok {
+					merged = &ts
+					mergedLine = line
+				} else {
+					emit(span, line)
+				}
+			} else { // try merge
+				// TODO: merge list contents
+				if next, ok := span.(TextSpan); ok && next.Typ == merged.Typ && next.NoSpace == merged.NoSpace { // ok to merge
+					mergedText := merged.Text
+					if !next.NoSpace && !strings.HasSuffix(mergedText, "\n") { // no space to start a line
+						mergedText += " "
+					}
+					mergedText += next.Text
+					merged = &TextSpan{
+						Typ:     merged.Typ,
+						Text:    mergedText,
+						NoSpace: merged.NoSpace,
+					}
+				} else if ts, ok := span.(TextSpan); ok { // no match, start a new range
+					emit(*merged, mergedLine)
+					merged = &ts
+					mergedLine = line
+				} else { // no match, don't merge
+					emit(*merged, mergedLine)
+					emit(span, line)
+					merged = nil
+				}
+			}
+
+		}
+		if merged != nil {
+			emit(*merged, mergedLine)
+		}
+		section.Contents = contents
+		section.Lines = lines
+		page.Sections[i] = section
+
+	}
+}
+
+// Length of the font escape at the start of s: \fX, \f(XX, or \f[name].
+func fontEscapeLen(s string) int {
+	if len(s) < 3 {
+		return len(s)
+	}
+	switch s[2] {
+	case '(':
+		return min(5, len(s))
+	case '[':
+		if end := strings.IndexByte(s, ']'); end != -1 {
+			return end + 1
+		}
+		return len(s)
+	}
+	return 3
+}
+
+var spaceRun = regexp.MustCompile(`^ +| {2,}`)
+
+// Parse a line of an unfilled display, keeping the runs of spaces parseLine
+// would collapse, like indentation in a code sample.
+func (p *parser) parseUnfilled(line string) []Span {
+	var res []Span
+	start := 0
+	for _, loc := range spaceRun.FindAllStringIndex(line, -1) {
+		res = append(res, p.parseLine(line[start:loc[0]])...)
+		run := line[loc[0]:loc[1]]
+		if loc[0] > 0 { // the span before already ends in a space
+			run = run[1:]
+		}
+		res = append(res, TextSpan{TagPlain, run, true})
+		start = loc[1]
+	}
+	return append(res, p.parseLine(line[start:])...)
+}
+
+// Escapes in a line that nextToken shows as plain text because it doesn't
+// understand them, like \*(lq, or \(xx with a name that isn't known.
+func unknownEscapes(line string) []string {
+	var unknown []string
+	for i := 0; i < len(line)-1; i++ {
+		if line[i] != '\\' {
+			continue
+		}
+		if n, name := specialCharEscape(line[i:]); n > 0 {
+			if _, ok := specialChar(name); !ok {
+				unknown = append(unknown, line[i:i+n])
+			}
+			i += n - 1
+			continue
+		}
+		switch line[i+1] {
+		case 'f':
+			i += fontEscapeLen(line[i:]) - 1
+			continue
+		case '*', 'n': // strings and registers are named like fonts
+			n := fontEscapeLen(line[i:])
+			unknown = append(unknown, line[i:i+n])
+			i += n - 1
+			continue
+		case '&', '-', '.', ' ':
+		default:
+			unknown = append(unknown, line[i:i+2])
+		}
+		i++
+	}
+	return unknown
+}
+
+// s without its font escapes, for text that's shown in a single font.
+func withoutFontEscapes(s string) string {
+	var res strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], `\f`) {
+			i += fontEscapeLen(s[i:]) - 1
+			continue
+		}
+		res.WriteByte(s[i])
+	}
+	return res.String()
+}
+
+// The font name in a font escape, e.g. B for \fB and CW for \f(CW or \f[CW].
+func fontName(escape string) string {
+	name := escape[2:]
+	name = strings.TrimPrefix(name, "(")
+	name = strings.TrimPrefix(name, "[")
+	return strings.TrimSuffix(name, "]")
+}
+
+func (p *parser) selectFont(name string) {
+	if name == "P" || name == "" { // use previous font
+		p.currentFont = p.lastFont
+		return
+	}
+
+	p.lastFont = p.currentFont
+	switch name {
+	case "B", "3":
+		p.currentFont = fontBold
+	case "I", "2":
+		p.currentFont = fontItalic
+	case "BI", "4":
+		p.currentFont = fontBoldItalic
+	case "CW", "CR", "C":
+		p.currentFont = fontLiteral
+	default: // R, 1, and unknown fonts
+		p.currentFont = fontPlain
+	}
+}
+
+func nextToken(input string) (string, string) {
+	if len(input) == 0 {
+		return "", ""
+	}
+
+	inQuote := false
+	token := ""
+	skip := 0 // end of the escape that was just read
+
+	for i, c := range input {
+		if i < skip {
+			continue
+		}
+		if n, name := specialCharEscape(input[i:]); n > 0 { // \(xx or \[name]
+			if char, ok := specialChar(name); ok {
+				token += char
+			} else {
+				token += name
+			}
+			skip = i + n
+		} else if c == '\\' && i+1 < len(input) && input[i+1] == 'f' { // font sequence, this will be the next token
+			if inQuote {
+				token += "\\"
+			} else if i == 0 {
+				n := fontEscapeLen(input)
+				return input[:n], input[n:] // \fX is the current token
+			} else {
+				return token, input[i:] // \fX will be the next token
+			}
+		} else if c == '\\' && i+1 < len(input) && input[i+1] == '&' {
+			// keep the zero-width space, so it joins the text around it and
+			// stops a word like \&Fl being read as a macro. It's removed once
+			// the token is used.
+			token += "\\"
+		} else if c == '\\' {
+			// don't add \
+		} else if c == '"' && !inQuote { // start quoted words
+			inQuote = true
+		} else if c == '"' && inQuote { // end quoted words
+			inQuote = false
+		} else if c == ' ' && !inQuote {
+			return token, input[i+1:]
+		} else {
+			token += string(c)
+		}
+	}
+	return token, ""
+}
+
+// Remove the zero-width space \& from text. nextToken keeps it so a token like
+// \&Fl isn't taken for a macro, and it's removed once the token is used.
+func unescapeZeroWidth(s string) string {
+	return strings.ReplaceAll(s, `\&`, "")
+}
+
+// The span with the zero-width spaces removed from its text.
+func withoutZeroWidth(span Span) Span {
+	switch span := span.(type) {
+	case TextSpan:
+		span.Text = unescapeZeroWidth(span.Text)
+		return span
+	case FlagSpan:
+		span.Flag = unescapeZeroWidth(span.Flag)
+		return span
+	case ManRef:
+		span.Name = unescapeZeroWidth(span.Name)
+		span.Section = unescapeZeroWidth(span.Section)
+		return span
+	case StandardRef:
+		span.Standard = unescapeZeroWidth(span.Standard)
+		return span
+	case LibraryRef:
+		span.Library = unescapeZeroWidth(span.Library)
+		return span
+	}
+	return span
+}
+
+// Macros handled by parseLine. Any other macro at the start of a control line
+// is recorded as a warning.
+var inlineMacros = map[string]bool{
+	"Fl": true, "Cm": true, "Ic": true, "Ar": true, "Ev": true, "Va": true,
+	"Dv": true, "Pa": true, "Sy": true, "Li": true, "St": true, "Ta": true,
+	"No": true, "B": true, "I": true, "Em": true, "BR": true, "RB": true,
+	"RI": true, "IR": true, "BI": true, "IB": true, "Ns": true, "Ql": true,
+	"Pq": true, "Sq": true, "Dq": true, "Op": true, "Cd": true, "Vt": true,
+	"Ft": true, "Fn": true, "Xr": true,
+	"Ms": true, "Lb": true, "Oo": true, "Oc": true, "Po": true, "Pc": true,
+	"So": true, "Sc": true, "Do": true, "Dc": true, "Brq": true, "Bro": true,
+	"Brc": true,
+}
+
+// Macros that start a new paragraph or section, so already leave a blank line.
+var sectionBreaks = map[string]bool{
+	"Pp": true, "PP": true, "P": true, "LP": true,
+	"Sh": true, "SH": true, "Ss": true, "SS": true,
+}
+
+// Macros that expand to fixed text. %s is replaced by the name given after
+// -std, or the page name.
+var boilerplate = map[string]string{
+	"Bt": "is currently in beta test.",
+	"Ud": "currently under development.",
+	"Ex": "The %s utility exits 0 on success, and >0 if an error occurs.",
+	"Rv": "The %s() function returns the value 0 if successful; otherwise the value -1 is returned and the global variable errno is set to indicate the error.",
+}
+
+// The manual each section belongs to, which mdoc pages get from .Dt.
+var volumes = map[string]string{
+	"1": "General Commands Manual",
+	"2": "System Calls Manual",
+	"3": "Library Functions Manual",
+	"4": "Device Drivers Manual",
+	"5": "File Formats Manual",
+	"6": "Games Manual",
+	"7": "Miscellaneous Information Manual",
+	"8": "System Manager's Manual",
+	"9": "Kernel Developer's Manual",
+}
+
+// Volume names that can be given in place of an architecture after .Dt
+var volumeKeywords = map[string]string{
+	"USD":   "User's Supplementary Documents",
+	"PS1":   "Programmer's Supplementary Documents",
+	"AMD":   "Ancestral Manual Documents",
+	"SMM":   "System Manager's Manual",
+	"URM":   "User's Reference Manual",
+	"PRM":   "Programmer's Manual",
+	"KM":    "Kernel Manual",
+	"IND":   "Manual Master Index",
+	"LOCAL": "Local Manual",
+	"CON":   "Contributed Software Manual",
+}
+
+// Date formats seen in .Dd, most common first
+var dateLayouts = []string{
+	"January 2, 2006",
+	"January 2 2006",
+	"Jan 2, 2006",
+	"Jan 2 2006",
+	"2006-01-02",
+}
+
+// Normalize a .Dd date like "$Mdocdate: July 4 2020 $" to "July 4, 2020".
+// Dates we can't parse are returned as is.
+func parseDate(date string) string {
+	if strings.HasPrefix(date, "$Mdocdate") {
+		date = strings.TrimPrefix(date, "$Mdocdate")
+		date = strings.TrimPrefix(date, ":")
+		date = strings.TrimSpace(strings.TrimSuffix(date, "$"))
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t.Format("January 2, 2006")
+		}
+	}
+	return date
+}
+
+// The name of the macro on a control line, like "Sh" for ".Sh NAME".
+func macroName(line string) string {
+	name, _ := nextToken(line[1:])
+	return name
+}
+
+// Macros of the man macro set. Other macros starting with a capital letter are
+// mdoc's, and the lowercase roff requests belong to both.
+var manMacros = map[string]bool{
+	"TH": true, "SH": true, "SS": true, "PP": true, "P": true, "LP": true,
+	"IP": true, "TP": true, "TQ": true, "HP": true, "PD": true, "RS": true,
+	"RE": true, "B": true, "I": true, "BR": true, "RB": true, "RI": true,
+	"IR": true, "BI": true, "IB": true, "SM": true, "SB": true, "UR": true,
+	"UE": true, "MT": true, "ME": true, "EX": true, "EE": true, "OP": true,
+	"SY": true, "YS": true, "DT": true, "UC": true, "AT": true,
+}
+
+// Whether macro is understood in the macro set the page is read as. Pages
+// read as FormatAuto understand both.
+func (p *parser) inMacroSet(macro string) bool {
+	switch {
+	case p.format == FormatAuto, macro == "", macro[0] < 'A' || macro[0] > 'Z':
+		return true
+	case p.format == FormatMan:
+		return manMacros[macro]
+	}
+	return !manMacros[macro]
+}
+
+// The text of a boilerplate macro with arguments args.
+func expandBoilerplate(text, args, pageName string) string {
+	if !strings.Contains(text, "%s") {
+		return text
+	}
+	name := pageName
+	for arg, rest := nextToken(args); arg != "" || rest != ""; arg, rest = nextToken(rest) {
+		if arg != "" && arg != "-std" {
+			name = arg
+			break
+		}
+	}
+	return fmt.Sprintf(text, name)
+}
+
+// All the tokens in line, unquoted and separated by single spaces, as text.
+func joinTokens(line string) string {
+	var words []string
+	for word, rest := nextToken(line); word != "" || rest != ""; word, rest = nextToken(rest) {
+		if word != "" {
+			words = append(words, unescapeZeroWidth(word))
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// The name given to .Sh or .Ss, without quotes or runs of spaces, so "SEE
+// ALSO" is found however it was written.
+func headerName(args string) string {
+	return strings.Join(strings.Fields(joinTokens(args)), " ")
+}
+
+// The arguments to an inline macro that takes several, like .Ev HOME PATH, up
+// to the next macro or punctuation, and what's left of the line after them.
+func inlineArgs(line string) ([]string, string) {
+	var args []string
+	for {
+		token, rest := nextToken(line)
+		if token == "" && rest != "" { // eat spaces
+			line = rest
+			continue
+		}
+		if token == "" || inlineMacros[token] || isDelimiter(token) {
+			return args, line
+		}
+		args = append(args, token)
+		line = rest
+	}
+}
+
+// The span with no space after it, if it's a kind that can have one.
+func withoutSpace(span Span) (Span, bool) {
+	switch span := span.(type) {
+	case TextSpan:
+		span.NoSpace = true
+		return span, true
+	case FlagSpan:
+		span.NoSpace = true
+		return span, true
+	case DecoratedSpan:
+		span.NoSpace = true
+		return span, true
+	case decorationMarker:
+		span.NoSpace = true
+		return span, true
+	case LinkSpan:
+		span.NoSpace = true
+		return span, true
+	case StandardRef:
+		span.NoSpace = true
+		return span, true
+	case ManRef:
+		span.NoSpace = true
+		return span, true
+	case LibraryRef:
+		span.NoSpace = true
+		return span, true
+	}
+	return span, false
+}
+
+// Whether token is a single delimiter, which ends a macro's arguments. Longer
+// runs like "..." are arguments themselves.
+func isDelimiter(token string) bool {
+	return len(token) == 1 && strings.Contains(".,:;()[]?!|", token)
+}
+
+// Whether token is only delimiters, like "," or ").".
+func isPunctuation(token string) bool {
+	return strings.Trim(token, ".,:;()[]?!|") == ""
+}
+
+func (p *parser) parseLine(line string) []Span {
+	if line == "" {
+		return nil
+	}
+
+	var res []Span
+	lastMacro := ""
+	repeatMacro := false
+
+tokenizer:
+	for {
+		token, rest := nextToken(line)
+		if token == "" && len(rest) > 0 { // eat spaces
+			line = rest
+			continue
+		}
+		if strings.HasPrefix(token, "\\f") && fontEscapeLen(token) == len(token) { // font escape
+			p.selectFont(fontName(token))
+			line = rest
+			continue
+		}
+		if !p.inMacroSet(token) { // a word, like No in man text
+			token = `\&` + token
+		}
+		switch token {
+		case "Fl": // command line flags with dash
+			flags, rest := inlineArgs(rest)
+			if len(flags) == 0 { // a lone dash
+				flags = []string{""}
+			}
+			for _, flag := range flags {
+				res = append(res, FlagSpan{flag, true, false})
+			}
+			line = rest
+			lastMacro = "Fl"
+		case "Cm", "Ic": // command line something with no dash
+			flags, rest := inlineArgs(rest)
+			for _, flag := range flags {
+				res = append(res, FlagSpan{flag, false, false})
+			}
+			line = rest
+			lastMacro = "Cm"
+		case "Ar": // command line arguments
+			args, rest := inlineArgs(rest)
+			if len(args) == 0 {
+				args = []string{"file ..."}
+			}
+			for _, arg := range args {
+				res = append(res, TextSpan{TagArg, arg, false})
+			}
+			line = rest
+			lastMacro = "Ar"
+		case "Ev": // environment variables
+			vars, rest := inlineArgs(rest)
+			for _, env := range vars {
+				res = append(res, TextSpan{TagEnvVar, env, false})
+			}
+			line = rest
+			lastMacro = "Ev"
+		case "Va": // variables
+			vars, rest := inlineArgs(rest)
+			for _, vari := range vars {
+				res = append(res, TextSpan{TagVariable, vari, false})
+			}
+			line = rest
+			lastMacro = "Va"
+		case "Dv": // defined constants
+			constants, rest := inlineArgs(rest)
+			for _, constant := range constants {
+				res = append(res, TextSpan{TagConstant, constant, false})
+			}
+			line = rest
+			lastMacro = "Dv"
+		case "Vt", "Ft": // variable or function type
+			typ, rest := nextToken(rest)
+			res = append(res, TextSpan{TagType, typ, false})
+			line = rest
+			lastMacro = token
+		case "Xr": // man reference, like ls(1)
+			name, next := nextToken(rest)
+			section, after := nextToken(next)
+			if section == "" || isDelimiter(section) || inlineMacros[section] {
+				section, after = "", next
+			}
+			res = append(res, ManRef{name, section, false})
+			line = after
+			lastMacro = "Xr"
+		case "Fn": // function name and arguments, like read(fd, buf, n)
+			name, next := nextToken(rest)
+			args, next := inlineArgs(next)
+			res = append(res, TextSpan{TagFunction, name, true}, TextSpan{TagPlain, "(", true})
+			for i, arg := range args {
+				if i > 0 {
+					res = append(res, TextSpan{TagPlain, ",", false})
+				}
+				res = append(res, TextSpan{TagArg, arg, true})
+			}
+			res = append(res, TextSpan{TagPlain, ")", false})
+			line = next
+			lastMacro = "Fn"
+		case "Pa": // paths, the home directory if there's none
+			paths, rest := inlineArgs(rest)
+			if len(paths) == 0 {
+				paths = []string{"~"}
+			}
+			for _, pa := range paths {
+				res = append(res, TextSpan{TagPath, pa, false})
+			}
+			line = rest
+			lastMacro = "Pa"
+		case "Sy": // symbolic
+			syms, rest := inlineArgs(rest)
+			for _, sym := range syms {
+				res = append(res, TextSpan{TagSymbolic, sym, false})
+			}
+			line = rest
+			lastMacro = "Sy"
+		case "Li": // literal
+			literal, rest := nextToken(rest)
+			res = append(res, TextSpan{TagLiteral, literal, false})
+			line = rest
+			lastMacro = "Li"
+		case "St": // standard
+			standard, rest := nextToken(rest)
+			res = append(res, StandardRef{standard, false})
+			line = rest
+			lastMacro = "St"
+		case "Cd": // kernel configuration declaration, takes the rest of the line
+			res = append(res, TextSpan{TagConfig, joinTokens(rest), false})
+			break tokenizer
+		case "Ms": // math symbol
+			sym, rest := nextToken(rest)
+			res = append(res, TextSpan{TagSymbolic, sym, false})
+			line = rest
+			lastMacro = "Ms"
+		case "Lb": // library
+			library, rest := nextToken(rest)
+			res = append(res, LibraryRef{library, false})
+			line = rest
+			lastMacro = "Lb"
+		case "Ta": // table cell separator
+			res = append(res, TextSpan{TagTableCellSeparator, "", false})
+			line = rest
+			lastMacro = "Ta"
+		case "No": // no format
+			no, rest := nextToken(rest)
+			res = append(res, TextSpan{TagPlain, no, false})
+			line = rest
+			lastMacro = "No"
+		case "B": // bold
+			bold, rest := nextToken(rest)
+			res = append(res, TextSpan{TagBold, bold, false})
+			line = rest
+			lastMacro = "B"
+		case "I": // italic
+			italic, rest := nextToken(rest)
+			res = append(res, TextSpan{TagItalic, italic, false})
+			line = rest
+			lastMacro = "I"
+		case "Em": // emphasis or underline
+			em, rest := nextToken(rest)
+			res = append(res, TextSpan{TagUnderline, em, false})
+			line = rest
+			lastMacro = "Em"
+		case "BR", "RB", "RI", "IR", "BI", "IB": // alternating fonts
+			res = append(res, alternateFonts(token, rest)...)
+			break tokenizer
+		case "Ns": // no space
+			if len(res) > 0 {
+				res[len(res)-1], _ = withoutSpace(res[len(res)-1])
+			}
+			line = rest
+		case "Ql": // quoted literal
+			res = append(res, DecoratedSpan{DecorationQuotedLiteral, foldDecorations(p.parseLine(rest)), false})
+			break tokenizer
+		case "Pq": // parens
+			res = append(res, DecoratedSpan{DecorationParens, foldDecorations(p.parseLine(rest)), false})
+			break tokenizer
+		case "Sq": // single quote
+			res = append(res, DecoratedSpan{DecorationSingleQuote, foldDecorations(p.parseLine(rest)), false})
+			break tokenizer
+		case "Dq": // double quote
+			res = append(res, DecoratedSpan{DecorationDoubleQuote, foldDecorations(p.parseLine(rest)), false})
+			break tokenizer
+		case "Op": // optional
+			res = append(res, DecoratedSpan{DecorationOptional, foldDecorations(p.parseLine(rest)), false})
+			break tokenizer
+		case "Brq": // braces
+			res = append(res, DecoratedSpan{DecorationBraces, foldDecorations(p.parseLine(rest)), false})
+			break tokenizer
+		case "Oo", "Oc", "Po", "Pc", "So", "Sc", "Do", "Dc", "Bro", "Brc": // open or close a decoration
+			res = append(res, decorationMarkers[token])
+			line = rest
+
+		// escape sequences
+		case "\\-", "\\,", "\\/":
+			res = append(res, TextSpan{TagPlain, token[1:2], true})
+			line = rest
+
+		case ".", ",", ";", ":", ")", "]", "?", "!": // closing punctuation, attached to what's before it
+			if len(res) > 0 {
+				if last, ok := withoutSpace(res[len(res)-1]); ok {
+					res[len(res)-1] = last
+				}
+			}
+			res = append(res, TextSpan{TagPlain, token, false})
+			line = rest
+			repeatMacro = true
+		case "(", "[": // opening punctuation, attached to what's after it
+			res = append(res, TextSpan{TagPlain, token, true})
+			line = rest
+			repeatMacro = true
+		case "|":
+			res = append(res, TextSpan{TagPlain, token, false})
+			line = rest
+			repeatMacro = true
+		case "":
+			break tokenizer
+		default:
+			if repeatMacro {
+				line = lastMacro + " " + line
+				repeatMacro = false
+			} else {
+				style := TagPlain
+				switch p.currentFont {
+				case fontPlain:
+					style = TagPlain
+				case fontBold:
+					style = TagBold
+				case fontItalic:
+					style = TagItalic
+				case fontBoldItalic:
+					style = TagBoldItalic
+				case fontLiteral:
+					style = TagLiteral
+				}
+				if strings.Contains(token, "\b") { // overstruck, as in a cat page
+					spans := parseOverstrike(token)
+					last := spans[len(spans)-1].(TextSpan)
+					last.NoSpace = false
+					res = append(append(res, spans[:len(spans)-1]...), last)
+				} else {
+					res = append(res, TextSpan{style, token, false})
+				}
+				line = rest
+			}
+		}
+	}
+
+	for i, span := range res {
+		res[i] = withoutZeroWidth(span)
+	}
+	return res
+}
+
+// The fonts of the alternating font macros, by the letters in their names.
+var alternatingFonts = map[byte]TextTag{'B': TagBold, 'I': TagItalic, 'R': TagPlain}
+
+// Expand a macro like .BR, which sets its arguments in alternating fonts with
+// no space between them, so .BR ls (1) is a bold ls followed by (1).
+func alternateFonts(macro, args string) []Span {
+	var res []Span
+	for i := 0; ; i++ {
+		if args = strings.TrimLeft(args, " "); args == "" {
+			break
+		}
+		var word string
+		word, args = nextToken(args)
+		if word == "" { // an empty "" argument still takes its font's turn
+			continue
+		}
+		res = append(res, TextSpan{alternatingFonts[macro[i%2]], word, true})
+	}
+	if len(res) > 0 {
+		last := res[len(res)-1].(TextSpan)
+		last.NoSpace = false
+		res[len(res)-1] = last
+	}
+	return res
+}
+
+// Remove a \" comment, which runs to the end of the line. An escaped
+// backslash followed by a quote (\\") isn't a comment.
+func stripComment(line string) string {
+	for i := 0; i+1 < len(line); i++ {
+		if line[i] != '\\' {
+			continue
+		}
+		if line[i+1] == '"' {
+			return strings.TrimRight(line[:i], " \t")
+		}
+		i++ // skip the escaped character
+	}
+	return line
+}
+
+// Whether s ends in a backslash that isn't itself escaped.
+func endsInEscape(s string) bool {
+	trailing := len(s) - len(strings.TrimRight(s, "\\"))
+	return trailing%2 == 1
+}
+
+// Receives each line of a page, with the (0-based) input line it started on.
+// The parser is a pipeline of these, so a page never has to be split into
+// lines all at once. An error stops the page being read.
+type lineFunc func(line string, lineNo int) error
+
+// How the line being joined continues
+type continuation int
+
+const (
+	notContinued  continuation = iota
+	continued                  // ended in \, the next line continues it
+	continuedText              // ended in \c, the next text line continues it
+)
+
+// Joins lines ending in an escaped newline (\) or \c onto the following line.
+type continuationJoiner struct {
+	next      lineFunc
+	pending   string // line that may be continued
+	pendingNo int
+	state     continuation
+}
+
+func (j *continuationJoiner) add(line string, lineNo int) error {
+	// macros still need their own line after \c
+	if j.state == continued || (j.state == continuedText && !strings.HasPrefix(line, ".")) {
+		line = j.pending + line
+		lineNo = j.pendingNo
+	} else if err := j.flush(); err != nil {
+		return err
+	}
+
+	switch {
+	case endsInEscape(line):
+		j.pending, j.pendingNo, j.state = line[:len(line)-1], lineNo, continued
+	case strings.HasSuffix(line, "c") && endsInEscape(line[:len(line)-1]):
+		j.pending, j.pendingNo, j.state = line[:len(line)-2], lineNo, continuedText
+	default:
+		j.state = notContinued
+		return j.next(line, lineNo)
+	}
+	return nil
+}
+
+// Pass on a line left waiting for a continuation.
+func (j *continuationJoiner) flush() error {
+	if j.state != notContinued {
+		j.state = notContinued
+		return j.next(j.pending, j.pendingNo)
+	}
+	return nil
+}
+
+// Pass each line read from r to next, like strings.Split(doc, "\n") would
+// split it, without comments, carriage returns, or a byte order mark.
+func readLines(r io.Reader, next lineFunc) error {
+	reader := bufio.NewReader(r)
+	for lineNo := 0; ; lineNo++ {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if lineNo == 0 {
+			line = strings.TrimPrefix(line, "\uFEFF")
+		}
+		line = strings.ReplaceAll(line, "\r", "") // Windows line endings
+		if err := next(stripComment(line), lineNo); err != nil {
+			return err
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// Parse a page line by line as it's read from r. Source it can't make sense
+// of is returned as an error.
+func (p *parser) parseReader(r io.Reader) (Page, error) {
+	mdocTitle, _ := regexp.Compile(`^\.Dt\s+(\S+)\s+(\w+)(?:\s+(\S+))?`) // .Dt macro
+	savedName := ""
+	var names []string  // names in the NAME section, waiting for .Nd
+	var nameLines []int // the line each name is on
+
+	page := Page{}
+	var currentSection *Section
+
+	lists := stack[*List]{}
+
+	// decorations opened with .Oo and friends, maybe on an earlier line
+	decorations := stack[*DecoratedSpan]{}
+	decorationLines := stack[int]{} // the line each one started on
+	var link *LinkSpan              // open .UR or .MT link, collecting its text
+
+	srcLine := 0     // 1-based line currently being parsed
+	spacing := true  // .Sm mode, off to glue macro arguments together
+	var glued *Span  // the last span added with spacing off
+	var unglued Span // and how it was before, to restore with .Sm on
+	addSpans := func(spans ...Span) {
+		for _, span := range spans {
+			line := srcLine
+			if link != nil {
+				link.Contents = append(link.Contents, span)
+				continue
+			}
+			if marker, ok := span.(decorationMarker); ok {
+				if marker.Open {
+					decorations.Push(&DecoratedSpan{Typ: marker.Typ})
+					decorationLines.Push(srcLine)
+					continue
+				}
+				if decorations.Len() == 0 { // nothing to close
+					continue
+				}
+				closed := decorations.Pop()
+				closed.NoSpace = marker.NoSpace
+				span = *closed
+				line = decorationLines.Pop()
+			}
+
+			original := span
+			if !spacing {
+				span, _ = withoutSpace(span)
+			}
+			var added *Span
+			if decorations.Len() > 0 {
+				open := decorations.Peek()
+				open.Contents = append(open.Contents, span)
+				added = &open.Contents[len(open.Contents)-1]
+			} else if lists.Len() > 0 {
+				list := lists.Peek()
+				if len(list.Items) == 0 { // text before the first .It starts an item without a tag
+					list.Items = append(list.Items, ListItem{})
+				}
+				currentItem := &list.Items[len(list.Items)-1]
+				currentItem.Contents = append(currentItem.Contents, span)
+				currentItem.Lines = append(currentItem.Lines, line)
+				added = &currentItem.Contents[len(currentItem.Contents)-1]
+			} else {
+				if currentSection == nil { // text before the first section header
+					currentSection = &Section{Name: "DESCRIPTION"}
+				}
+				contents, lines := &currentSection.Contents, &currentSection.Lines
+				if p.lineLength > 0 || p.justify { // text since .ll or .ad goes in a block of its own
+					var block *Block
+					if n := len(*contents); n > 0 {
+						block, _ = (*contents)[n-1].(*Block)
+					}
+					if block == nil || block.LineLength != p.lineLength || block.Justify != p.justify {
+						block = &Block{LineLength: p.lineLength, Justify: p.justify}
+						*contents = append(*contents, block)
+						*lines = append(*lines, line)
+					}
+					contents, lines = &block.Contents, &block.Lines
+				}
+				*contents = append(*contents, span)
+				*lines = append(*lines, line)
+				added = &(*contents)[len(*contents)-1]
+			}
+			if !spacing {
+				glued, unglued = added, original
+			}
+		}
+	}
+	// add the open link, followed by punctuation from .UE or .ME
+	closeLink := func(punctuation string) {
+		if link == nil {
+			return
+		}
+		closed := *link
+		link = nil
+		closed.Contents = foldDecorations(closed.Contents)
+		closed.NoSpace = punctuation != ""
+		addSpans(closed)
+		if punctuation != "" {
+			addSpans(TextSpan{TagPlain, punctuation, false})
+		}
+	}
+	// add the names collected from .Nm in the NAME section, separated by commas
+	addNames := func() {
+		line := srcLine
+		for i, name := range names {
+			srcLine = nameLines[i]
+			if i > 0 {
+				addSpans(TextSpan{TagPlain, ",", false})
+			}
+			addSpans(TextSpan{TagNameRef, name, i < len(names)-1})
+		}
+		srcLine = line
+		names, nameLines = nil, nil
+	}
+	// close links and decorations left open at the end of a section
+	closeDecorations := func() {
+		addNames()
+		closeLink("")
+		for decorations.Len() > 0 {
+			addSpans(decorationMarker{})
+		}
+	}
+
+	includes := false   // the last line was an #include in the SYNOPSIS
+	display := ""       // the type of the open .Bd display, like -centered
+	displayIndent := 0  // tab widths the open display is offset by
+	describing := false // .Nd had no text, so the description may follow
+
+	// escapes we don't understand are shown as plain text, so note where
+	warnEscapes := func(line string, lineNo int) {
+		for _, escape := range unknownEscapes(line) {
+			page.Warnings = append(page.Warnings, Warning{Line: lineNo + 1, Raw: escape})
+		}
+	}
+
+	handleLine := func(line string, lineNo int) error {
+		srcLine = lineNo + 1
+		if strings.HasPrefix(line, ".") && !p.inMacroSet(macroName(line)) {
+			// a macro of the other set is unknown, so its line is shown as text
+			page.Warnings = append(page.Warnings, Warning{Line: lineNo + 1, Macro: macroName(line)})
+			line = `\&` + line[1:]
+		}
+		if describing && line != "" && line != "." {
+			// the dash goes before the description on the lines after .Nd, if any
+			describing = false
+			if !strings.HasPrefix(line, ".") || inlineMacros[macroName(line)] {
+				addSpans(TextSpan{TagDescriptionDash, "", false})
+			}
+		}
+		if includes && !strings.HasPrefix(line, ".In") && !strings.HasPrefix(line, ".Fd") {
+			// a blank line between the includes and the prototypes, unless a
+			// paragraph or section already starts one
+			includes = false
+			if !strings.HasPrefix(line, ".") || !sectionBreaks[macroName(line)] {
+				addSpans(TextSpan{TagPlain, "\n", true})
+			}
+		}
+		switch {
+
+		case strings.HasPrefix(line, ".Dd"): // document date
+			page.Date = parseDate(joinTokens(line[3:]))
+
+		case mdocTitle.MatchString(line): // mdoc page title
+			// .Dt name section [volume | arch]
+			parts := mdocTitle.FindStringSubmatch(line)
+			page.Name = unescapeZeroWidth(parts[1])
+			page.Section = parts[2]
+			page.Manual = volumes[page.Section[:1]]
+			if volume, ok := volumeKeywords[parts[3]]; ok {
+				page.Manual = volume
+			} else {
+				page.Arch = parts[3]
+			}
+
+		case strings.HasPrefix(line, ".TH"): // man page title
+			// .TH name section [date [source [manual]]]
+			parts, err := shlex.Split(line[3:]) // use shlex to handle quoting
+			if err != nil {
+				// an unbalanced quote, which roff ends at the end of the line
+				parts = macroArgs(line[3:])
+			}
+
+			fields := make([]string, 5)
+			copy(fields, parts)
+			for i, field := range fields {
+				fields[i] = unescapeZeroWidth(field)
+			}
+			page.Name, page.Section, page.Date = fields[0], fields[1], fields[2]
+			page.Source, page.Manual = fields[3], fields[4]
+
+		case strings.HasPrefix(line, ".Sh") || strings.HasPrefix(line, ".SH"): // section header
+			closeDecorations()
+			if currentSection != nil {
+				page.Sections = append(page.Sections, *currentSection)
+			}
+
+			name := headerName(line[3:])
+
+			currentSection = &Section{Name: name}
+
+		case line == ".Nm" || strings.HasPrefix(line, ".Nm "): // .Nm - page name
+			// the first argument is the name unless it's a macro or punctuation,
+			// otherwise use the name from the first invocation
+			name, rest := nextToken(strings.TrimSpace(line[3:]))
+			if name == "" || inlineMacros[name] || isPunctuation(name) {
+				name, rest = savedName, strings.TrimSpace(line[3:])
+			} else {
+				name = unescapeZeroWidth(name)
+				if savedName == "" { // first invocation, save the name
+					savedName = name
+				}
+			}
+
+			// names are listed together, whatever punctuation separates them
+			if currentSection.is("NAME") && lists.Len() == 0 {
+				names = append(names, name)
+				nameLines = append(nameLines, srcLine)
+				if rest = strings.TrimSpace(rest); rest != "" && !isPunctuation(joinTokens(rest)) {
+					addNames()
+					addSpans(p.parseLine(rest)...)
+				}
+				break
+			}
+
+			// each invocation in the synopsis starts a new line
+			if currentSection.is("SYNOPSIS") && len(currentSection.Contents) > 0 && lists.Len() == 0 {
+				addSpans(TextSpan{TagPlain, "\n", true})
+			}
+			addSpans(TextSpan{TagNameRef, name, false})
+			addSpans(p.parseLine(rest)...)
+
+		case strings.HasPrefix(line, ".Nd"): // page description
+			// text lines that follow are added to the description as usual
+			addNames()
+			if args := strings.TrimSpace(line[3:]); args != "" {
+				addSpans(TextSpan{TagDescriptionDash, "", false})
+				addSpans(p.parseLine(args)...)
+			} else {
+				describing = true
+			}
+
+		case strings.HasPrefix(line, ".In"): // #include
+			header, _ := nextToken(strings.TrimSpace(line[3:]))
+			header = unescapeZeroWidth(header)
+			addSpans(
+				TextSpan{TagPlain, "#include", false},
+				TextSpan{TagPlain, "<", true},
+				TextSpan{TagPath, header, true},
+				TextSpan{TagPlain, ">", true},
+			)
+			if currentSection.is("SYNOPSIS") { // one include per line
+				addSpans(TextSpan{TagPlain, "\n", true})
+				includes = true
+			}
+
+		case strings.HasPrefix(line, ".Fd"): // preprocessor directive
+			// keep quotes in #include "foo.h"
+			addSpans(TextSpan{TagBold, strings.TrimSpace(line[3:]), false})
+			if currentSection.is("SYNOPSIS") { // one directive per line
+				addSpans(TextSpan{TagPlain, "\n", true})
+				includes = true
+			}
+
+		case strings.HasPrefix(line, ".Fn ") && currentSection.is("SYNOPSIS"): // prototype
+			spans := p.parseLine(line[1:])
+			if last, ok := withoutSpace(spans[len(spans)-1]); ok {
+				spans[len(spans)-1] = last
+			}
+			addSpans(spans...)
+			addSpans(TextSpan{TagPlain, ";\n", true}) // one prototype per line
+
+		case strings.HasPrefix(line, ".") && (macroName(line) == "UR" || macroName(line) == "MT"): // hyperlink or mail address
+			closeLink("")
+			macro, args := nextToken(line[1:])
+			target, _ := nextToken(args)
+			if macro == "MT" {
+				target = "mailto:" + target
+			}
+			link = &LinkSpan{URL: target}
+
+		case strings.HasPrefix(line, ".") && (macroName(line) == "UE" || macroName(line) == "ME"): // end of link
+			_, punctuation := nextToken(line[1:])
+			closeLink(joinTokens(punctuation))
+
+		case strings.HasPrefix(line, ".Ss") || strings.HasPrefix(line, ".SS"): // subsection header
+			addSpans(TextSpan{TagSubsectionHeader, headerName(line[3:]), true})
+
+		case strings.HasPrefix(line, ".Dl"): // indented literal
+			addSpans(LiteralLine{foldDecorations(p.parseLine(strings.TrimSpace(line[3:])))})
+
+		case strings.HasPrefix(line, ".IP"): // indented paragraph
+			tag := ""
+			indent := 0
+			maxWidth := 8
+
+			if len(line) > 3 {
+				// split like macro arguments, so a tag like \fB-a\fR stays whole
+				args := macroArgs(line[4:])
+				if len(args) > 0 {
+					tag = joinTokens(withoutFontEscapes(args[0]))
+				}
+				if len(args) > 1 {
+					indent, _ = lengthColumns(args[1], 'n') // an indent that isn't a length is ignored
+				}
+			}
+
+			if tag == "" && indent == 0 { // just a paragraph
+				addSpans(TextSpan{TagPlain, p.itemGap(), false})
+				break
+			}
+			addSpans(TextSpan{TagPlain, p.itemGap() + strings.Repeat("  ", indent) + tag, false})
+			if indent+len(tag)+1 > maxWidth {
+				addSpans(TextSpan{TagPlain, "\n" + strings.Repeat(" ", maxWidth), false}) // TODO: proper IP support, like Bl
+			}
+
+		case strings.HasPrefix(line, ".TP"):
+			addSpans(TextSpan{TagPlain, p.itemGap(), false})
+
+		case line == ".PD" || strings.HasPrefix(line, ".PD "): // paragraph distance
+			distance, _ := nextToken(strings.TrimSpace(line[3:]))
+			p.compact = strings.TrimSuffix(distance, "v") == "0"
+
+		case strings.HasPrefix(line, ".ft"): // font
+			// not supported
+
+		case strings.HasPrefix(line, ".Bl"): // begin list
+			list := List{}
+
+			args, err := shlex.Split(line[4:])
+			if err != nil {
+				return parseError(lineNo+1, ".Bl", err)
+			}
+			for i := 0; i < len(args); i += 1 {
+				arg := args[i]
+
+				switch arg {
+				case "-bullet":
+					list.Typ = BulletList
+				case "-dash":
+					list.Typ = DashList
+				case "-enum":
+					list.Typ = EnumList
+				case "-tag":
+					list.Typ = TagList
+				case "-diag":
+					list.Typ = DiagList
+				case "-hang":
+					list.Typ = HangList
+				case "-ohang":
+					list.Typ = OhangList
+				case "-inset":
+					list.Typ = InsetList
+				case "-column":
+					list.Typ = ColumnList
+				case "-width":
+					if i+1 < len(args) {
+						i += 1
+						list.Width = len(args[i])
+					}
+				case "-compact":
+					list.Compact = true
+				case "-offset":
+					// TODO: handle left, center, indent, indent-two, right
+					i += 1
+				default:
+					if list.Typ == ColumnList {
+						list.Columns = append(list.Columns, arg)
+					}
+				}
+			}
+			for _, outer := range lists.items {
+				if outer.Typ == EnumList {
+					list.Depth++
+				}
+			}
+			lists.Push(&list)
+
+		case strings.HasPrefix(line, ".It"): // list item
+			nextItem := ListItem{}
+			if len(line) > 4 {
+				nextItem.Tag = foldDecorations(p.parseLine(line[4:]))
+			}
+			if lists.Len() == 0 {
+				page.Warnings = append(page.Warnings, Warning{Line: lineNo + 1, Misplaced: "It"})
+				return nil
+			}
+			lists.Peek().Items = append(lists.Peek().Items, nextItem)
+
+		case strings.HasPrefix(line, ".El"): // end list
+			if lists.Len() == 0 {
+				page.Warnings = append(page.Warnings, Warning{Line: lineNo + 1, Misplaced: "El"})
+				return nil
+			}
+			endedList := lists.Pop()
+			addSpans(endedList)
+
+		case strings.HasPrefix(line, ".Os"): // OS
+			page.OS = joinTokens(line[3:])
+
+		case line == ".Pp":
+			addSpans(TextSpan{TagPlain, "\n\n", false})
+
+		case line == ".PP" || line == ".P" || line == ".LP": // man paragraphs, spaced by .PD
+			addSpans(TextSpan{TagPlain, p.itemGap(), false})
+
+		case line == ".br":
+			addSpans(TextSpan{TagPlain, "\n", false})
+
+		case line == ".na": // no adjusting, ragged right
+			p.justify = false
+
+		case line == ".ad" || strings.HasPrefix(line, ".ad "): // adjust mode
+			mode, _ := nextToken(strings.TrimSpace(line[3:]))
+			// left, center, and right all leave the text unfilled
+			p.justify = mode == "" || mode == "b" || mode == "n"
+
+		case line == ".nh" || line == ".hy" || strings.HasPrefix(line, ".hy "):
+			// we never hyphenate, so there's nothing to turn off
+
+		case strings.HasPrefix(line, ".ds"):
+			// TODO: define string
+
+		case strings.HasPrefix(line, ".nr"):
+			// registers are set while evaluating conditionals
+
+		case line == ".Sm" || strings.HasPrefix(line, ".Sm "): // spacing mode
+			mode, _ := nextToken(strings.TrimSpace(line[3:]))
+			wasSpacing := spacing
+			spacing = mode == "on" || (mode != "off" && !spacing)
+			if spacing && !wasSpacing && glued != nil {
+				// the space comes back after the last glued span
+				*glued = unglued
+			}
+			glued = nil
+
+		case line == ".ll" || strings.HasPrefix(line, ".ll "): // line length
+			p.setLineLength(line[3:])
+
+		case line == "" && (display == "-literal" || display == "-unfilled"):
+			addSpans(UnfilledLine{nil, displayIndent, display == "-literal"})
+
+		case line == "." || line == "'" || line == "":
+			// ignore, including lines that only had a comment
+
+		case strings.HasPrefix(line, ".") && boilerplate[macroName(line)] != "":
+			macro, args := nextToken(line[1:])
+			addSpans(TextSpan{TagPlain, expandBoilerplate(boilerplate[macro], args, savedName), false})
+
+		case line == ".Bd" || strings.HasPrefix(line, ".Bd "): // begin display
+			// TODO: handle -offset for filled displays
+			display = ""
+			displayIndent = 0
+			compact := false
+			args := strings.Fields(line[3:])
+			for i, arg := range args {
+				switch arg {
+				case "-centered", "-filled", "-ragged", "-literal", "-unfilled":
+					display = arg
+				case "-compact":
+					compact = true
+				case "-offset":
+					if i+1 < len(args) && args[i+1] == "indent" {
+						displayIndent = 1
+					} else if i+1 < len(args) && args[i+1] == "indent-two" {
+						displayIndent = 2
+					}
+				}
+			}
+			if compact {
+				addSpans(TextSpan{TagPlain, "\n", false})
+			} else {
+				addSpans(TextSpan{TagPlain, "\n\n", false})
+			}
+
+		case line == ".Ed": // end display
+			if display == "-centered" || display == "-literal" || display == "-unfilled" { // the last line is already ended
+				addSpans(TextSpan{TagPlain, "\n", false})
+			} else {
+				addSpans(TextSpan{TagPlain, "\n\n", false})
+			}
+			display = ""
+
+		case display == "-centered" && !strings.HasPrefix(line, "."):
+			warnEscapes(line, lineNo)
+			addSpans(CenteredLine{foldDecorations(p.parseLine(line))})
+
+		case display == "-centered" && inlineMacros[macroName(line)]:
+			warnEscapes(line, lineNo)
+			addSpans(CenteredLine{foldDecorations(p.parseLine(line[1:]))})
+
+		case (display == "-literal" || display == "-unfilled") && !strings.HasPrefix(line, "."):
+			warnEscapes(line, lineNo)
+			addSpans(UnfilledLine{foldDecorations(p.parseUnfilled(line)), displayIndent, display == "-literal"})
+
+		case (display == "-literal" || display == "-unfilled") && inlineMacros[macroName(line)]:
+			warnEscapes(line, lineNo)
+			addSpans(UnfilledLine{foldDecorations(p.parseLine(line[1:])), displayIndent, display == "-literal"})
+
+		case strings.HasPrefix(line, "."):
+			if macro, _ := nextToken(line[1:]); !inlineMacros[macro] {
+				page.Warnings = append(page.Warnings, Warning{Line: lineNo + 1, Macro: macro})
+			}
+			warnEscapes(line, lineNo)
+			addSpans(p.parseLine(line[1:])...)
+
+		case strings.HasPrefix(line, "'"): // requests with the no-break control character aren't handled
+			page.Warnings = append(page.Warnings, Warning{Line: lineNo + 1, Raw: line})
+			addSpans(p.parseLine(line)...)
+
+		default:
+			warnEscapes(line, lineNo)
+			addSpans(p.parseLine(line)...)
+
+		}
+		return nil
+	}
+
+	conditionals := conditionalExpander{p: p, next: handleLine}
+	macros := macroExpander{p: p, next: conditionals.add}
+	joiner := continuationJoiner{next: macros.add}
+	if err := readLines(r, joiner.add); err != nil {
+		return Page{}, err
+	}
+	if err := joiner.flush(); err != nil {
+		return Page{}, err
+	}
+
+	closeDecorations()
+	if currentSection != nil {
+		page.Sections = append(page.Sections, *currentSection)
+	}
+	return page, nil
+}
@@ -0,0 +1,1016 @@
+package mandoc
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Collect the lines passed to a lineFunc.
+func collectLines(lines *[]string, lineNos *[]int) lineFunc {
+	return func(line string, lineNo int) error {
+		*lines = append(*lines, line)
+		*lineNos = append(*lineNos, lineNo)
+		return nil
+	}
+}
+
+// Parse a page that's expected to parse.
+func (p *parser) parseMdoc(doc string) Page {
+	page, _ := p.parseReader(strings.NewReader(doc)) // reading a string can't fail
+	return page
+}
+
+func TestNextToken(t *testing.T) {
+	tests := []struct {
+		line  string
+		token string
+		rest  string
+	}{
+		{"word", "word", ""},
+		{"a b c", "a", "b c"},
+		{".SH NAME", ".SH", "NAME"},
+
+		{".Fl t Ns Ar man ,", ".Fl", "t Ns Ar man ,"},
+		{"t Ns Ar man ,", "t", "Ns Ar man ,"},
+		{"Ns Ar man ,", "Ns", "Ar man ,"},
+		{"Ar man ,", "Ar", "man ,"},
+		{"man ,", "man", ","},
+
+		{`normal\fBbold`, "normal", `\fBbold`},
+		{`"quoted words" are handled`, "quoted words", "are handled"},
+
+		{`hel\fBlo\fR`, "hel", `\fBlo\fR`},
+		{`\fBhello`, `\fB`, "hello"},
+		{`\-\- ok`, `--`, `ok`},
+		{`"\-b\fIn\fP or \-\-buffers=\fIn\fP"`, `-b\fIn\fP or --buffers=\fIn\fP`, ""},
+
+		{`\f(CWcode\fR`, `\f(CW`, `code\fR`},
+		{`plain\f(CWcode`, "plain", `\f(CWcode`},
+		{`\f[CW]code\f[]`, `\f[CW]`, `code\f[]`},
+		{`\f[]rest`, `\f[]`, "rest"},
+		{`plain\f[BI]both`, "plain", `\f[BI]both`},
+		{`\f3bold\f1`, `\f3`, `bold\f1`},
+
+		{`\(em rest`, "—", "rest"},
+		{`caf\['e] au`, "café", "au"},
+		{`"\(lqquoted\(rq" x`, "“quoted”", "x"},
+		{`\(dq not a quote`, `"`, "not a quote"},
+		{`\[unknown] x`, "unknown", "x"},
+		{"b\bbo\bol\bld\bd text", "b\bbo\bol\bld\bd", "text"},
+		{"_\bu_\bn_\bd text", "_\bu_\bn_\bd", "text"},
+
+		{`foo\&bar baz`, `foo\&bar`, "baz"},
+		{`\&Fl x`, `\&Fl`, "x"},
+		{`\&. y`, `\&.`, "y"},
+		{`"a\&b" c`, `a\&b`, "c"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			token, rest := nextToken(test.line)
+			if token != test.token {
+				t.Errorf("nextToken(%q) = [%q, %q] wanted token %q", test.line, token, rest, test.token)
+			}
+			if rest != test.rest {
+				t.Errorf("nextToken(%q) = [%q, %q] wanted rest %q", test.line, token, rest, test.rest)
+			}
+		})
+	}
+}
+
+func TestSpecialChar(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		ok       bool
+	}{
+		{"em", "—", true},
+		{"co", "©", true},
+		{"<=", "≤", true},
+		{"*a", "α", true},
+		{"'e", "é", true},
+		{":u", "ü", true},
+		{",c", "ç", true},
+		{"^o", "ô", true},
+		{"/o", "ø", true},
+		{"ss", "ß", true},
+		{"u00E9", "é", true},
+		{"u1F600", "😀", true},
+		{"'1", "", false},
+		{"uXYZW", "", false},
+		{"nonsense", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			char, ok := specialChar(test.name)
+			if char != test.expected || ok != test.ok {
+				t.Errorf("specialChar(%q) = [%q, %v] wanted [%q, %v]", test.name, char, ok, test.expected, test.ok)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	page := Page{
+		Sections: []Section{
+			{
+				Contents: []Span{
+					TextSpan{Typ: TagPlain, Text: "hello"},
+					TextSpan{Typ: TagPlain, Text: "world"},
+					TextSpan{Typ: TagPlain, Text: "man"},
+					TextSpan{Typ: TagBold, Text: "bold"},
+				},
+			},
+		},
+	}
+	page.mergeSpans()
+	expected := []Span{
+		TextSpan{Typ: TagPlain, Text: "hello world man"},
+		TextSpan{Typ: TagBold, Text: "bold"},
+	}
+	if !slices.Equal(page.Sections[0].Contents, expected) {
+		t.Errorf("%+v did not equal %+v", page.Sections[0].Contents, expected)
+	}
+
+}
+
+func TestNoSpaceAfterLineEnd(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh DESCRIPTION\nbefore\n.Pp\nafter")
+	page.mergeSpans()
+	if res := page.Sections[0].Render(80, DefaultOptions()); !strings.HasSuffix(res, "\n\nafter") {
+		t.Errorf("rendered %q, wanted the paragraph to start without a space", res)
+	}
+}
+
+func TestUnknownMacroWarnings(t *testing.T) {
+	doc := ".Sh NAME\n.Fl v\n.Zz unknown\n.Ar file"
+	p := parser{}
+	page := p.parseMdoc(doc)
+	expected := []Warning{{Line: 3, Macro: "Zz"}}
+	if !slices.Equal(page.Warnings, expected) {
+		t.Errorf("got warnings %+v, wanted %+v", page.Warnings, expected)
+	}
+}
+
+func TestTextBeforeFirstItem(t *testing.T) {
+	doc := ".Sh COMMANDS\n.Bl -tag -width Ds\n.Tg attach\n.It Ic attach-session\nAttach.\n.El"
+	p := parser{}
+	page := p.parseMdoc(doc)
+	list := page.Sections[0].Contents[0].(*List)
+	if len(list.Items) != 2 || list.Items[0].Tag != nil {
+		t.Errorf("got items %+v, wanted one without a tag for the text before .It", list.Items)
+	}
+}
+
+func TestMisplacedListMacros(t *testing.T) {
+	doc := ".Sh FORMAT\n.Bl -tag\n.It one\nfirst\n.El\n.It two\nsecond\n.El"
+	p := parser{}
+	page := p.parseMdoc(doc)
+	expected := []Warning{{Line: 6, Misplaced: "It"}, {Line: 8, Misplaced: "El"}}
+	if !slices.Equal(page.Warnings, expected) {
+		t.Errorf("got warnings %+v, wanted %+v", page.Warnings, expected)
+	}
+	if res := page.Sections[0].Render(80, DefaultOptions()); !strings.HasSuffix(res, "second") {
+		t.Errorf("rendered %q, wanted the text after the stray .It kept", res)
+	}
+}
+
+func TestRawTextWarnings(t *testing.T) {
+	doc := ".Sh NAME\nsay \\*(lqhi\\*(rq \\(em \\fBbold\\fR \\-v\n'br\n.Ar \\(zz \\[u00E9]"
+	p := parser{}
+	page := p.parseMdoc(doc)
+	expected := []Warning{
+		{Line: 2, Raw: `\*(lq`},
+		{Line: 2, Raw: `\*(rq`},
+		{Line: 3, Raw: "'br"},
+		{Line: 4, Raw: `\(zz`},
+	}
+	if !slices.Equal(page.Warnings, expected) {
+		t.Errorf("got warnings %+v, wanted %+v", page.Warnings, expected)
+	}
+}
+
+func TestSpanLines(t *testing.T) {
+	doc := ".Sh NAME\n.Nm ls\n.Nd list directory contents\n.Sh DESCRIPTION\n.Fl a\n.Pp\n.Ar file"
+	p := parser{}
+	page := p.parseMdoc(doc)
+	page.mergeSpans()
+
+	expected := [][]int{{2, 3, 3}, {5, 6, 7}}
+	for i, section := range page.Sections {
+		if len(section.Lines) != len(section.Contents) {
+			t.Fatalf("section %s has %d spans but %d lines", section.Name, len(section.Contents), len(section.Lines))
+		}
+		if !slices.Equal(section.Lines, expected[i]) {
+			t.Errorf("section %s has lines %v, wanted %v", section.Name, section.Lines, expected[i])
+		}
+	}
+}
+
+func TestCRLF(t *testing.T) {
+	doc := ".TH LS 1 2024-01-01\n.SH NAME\nls \\- list directory contents\n.SH SYNOPSIS\n.B ls\n[\\fIOPTION\\fR]"
+	crlf := "\uFEFF" + strings.ReplaceAll(doc, "\n", "\r\n")
+
+	lf := parser{}
+	expected := lf.parseMdoc(doc)
+	p := parser{}
+	page := p.parseMdoc(crlf)
+
+	if !reflect.DeepEqual(page, expected) {
+		t.Errorf("CRLF parse %+v did not equal LF parse %+v", page, expected)
+	}
+	if page.Name != "LS" || page.Section != "1" {
+		t.Errorf("CRLF title parsed as %s(%s)", page.Name, page.Section)
+	}
+}
+
+func TestMultiLineNd(t *testing.T) {
+	doc := ".Sh NAME\n.Nm frob\n.Nd frobnicate the\nwidgets of\n.Em any\nkind"
+	p := parser{}
+	page := p.parseMdoc(doc)
+	page.mergeSpans()
+	expected := []Span{
+		TextSpan{Typ: TagNameRef, Text: "frob"},
+		TextSpan{Typ: TagDescriptionDash},
+		TextSpan{Typ: TagPlain, Text: "frobnicate the widgets of"},
+		TextSpan{Typ: TagUnderline, Text: "any"},
+		TextSpan{Typ: TagPlain, Text: "kind"},
+	}
+	if !slices.Equal(page.Sections[0].Contents, expected) {
+		t.Errorf("%+v did not equal %+v", page.Sections[0].Contents, expected)
+	}
+}
+
+func TestEmptyNd(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh NAME\n.Nm frob\n.Nd\n.Sh DESCRIPTION\nText")
+	page.mergeSpans()
+	expected := []Span{TextSpan{Typ: TagNameRef, Text: "frob"}}
+	if !slices.Equal(page.Sections[0].Contents, expected) {
+		t.Errorf("%+v did not equal %+v", page.Sections[0].Contents, expected)
+	}
+
+	page = p.parseMdoc(".Sh NAME\n.Nm frob\n.Nd\nfrobnicate widgets")
+	page.mergeSpans()
+	expected = []Span{
+		TextSpan{Typ: TagNameRef, Text: "frob"},
+		TextSpan{Typ: TagDescriptionDash},
+		TextSpan{Typ: TagPlain, Text: "frobnicate widgets"},
+	}
+	if !slices.Equal(page.Sections[0].Contents, expected) {
+		t.Errorf("%+v did not equal %+v", page.Sections[0].Contents, expected)
+	}
+}
+
+func TestNdWithReference(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh NAME\n.Nm frob\n.Nd create a Xr foo 1 archive")
+	page.mergeSpans()
+	expected := []Span{
+		TextSpan{Typ: TagNameRef, Text: "frob"},
+		TextSpan{Typ: TagDescriptionDash},
+		TextSpan{Typ: TagPlain, Text: "create a"},
+		ManRef{"foo", "1", false},
+		TextSpan{Typ: TagPlain, Text: "archive"},
+	}
+	if !slices.Equal(page.Sections[0].Contents, expected) {
+		t.Errorf("%+v did not equal %+v", page.Sections[0].Contents, expected)
+	}
+	if res := page.Sections[0].Render(80, DefaultOptions()); !strings.Contains(res, "create a foo(1) archive") {
+		t.Errorf("rendered %q", res)
+	}
+}
+
+func TestSynopsisNm(t *testing.T) {
+	doc := `.Sh NAME
+.Nm cp
+.Nd copy files
+.Sh SYNOPSIS
+.Nm
+.Op Fl R
+.Ar source
+.Nm
+.Ar directory
+.Nm Fl h`
+	p := parser{}
+	page := p.parseMdoc(doc)
+	page.mergeSpans()
+	expected := []Span{
+		TextSpan{Typ: TagNameRef, Text: "cp"},
+		DecoratedSpan{DecorationOptional, []Span{FlagSpan{"R", true, false}}, false},
+		TextSpan{Typ: TagArg, Text: "source"},
+		TextSpan{Typ: TagPlain, Text: "\n", NoSpace: true},
+		TextSpan{Typ: TagNameRef, Text: "cp"},
+		TextSpan{Typ: TagArg, Text: "directory"},
+		TextSpan{Typ: TagPlain, Text: "\n", NoSpace: true},
+		TextSpan{Typ: TagNameRef, Text: "cp"},
+		FlagSpan{"h", true, false},
+	}
+	if !reflect.DeepEqual(page.Sections[1].Contents, expected) {
+		t.Errorf("%+v did not equal %+v", page.Sections[1].Contents, expected)
+	}
+}
+
+func TestSynopsisIn(t *testing.T) {
+	doc := ".Sh SYNOPSIS\n.In stdio.h\n.In stdlib.h"
+	p := parser{}
+	page := p.parseMdoc(doc)
+
+	rendered := ""
+	for _, span := range page.Sections[0].Contents {
+		rendered += span.Render(80, DefaultOptions())
+	}
+	lines := strings.Split(strings.TrimSpace(rendered), "\n")
+	expected := []string{"#include <stdio.h>", "#include <stdlib.h>"}
+	if !slices.Equal(lines, expected) {
+		t.Errorf("rendered %q, wanted %q", lines, expected)
+	}
+
+	// a bare .In has no header to include
+	page = p.parseMdoc(".Sh SYNOPSIS\n.In\n.In stdio.h")
+	rendered = ""
+	for _, span := range page.Sections[0].Contents {
+		rendered += span.Render(80, DefaultOptions())
+	}
+	lines = strings.Split(strings.TrimSpace(rendered), "\n")
+	expected = []string{"#include <>", "#include <stdio.h>"}
+	if !slices.Equal(lines, expected) {
+		t.Errorf("rendered %q, wanted %q", lines, expected)
+	}
+}
+
+func TestParseCd(t *testing.T) {
+	tests := []struct {
+		line string
+		text string
+	}{
+		{`Cd "device foo at bar"`, "device foo at bar"},
+		{`Cd options  FOO_DEBUG`, "options FOO_DEBUG"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			p := parser{}
+			spans := p.parseLine(test.line)
+			expected := []Span{TextSpan{Typ: TagConfig, Text: test.text}}
+			if !slices.Equal(spans, expected) {
+				t.Errorf("parseLine(%q) = %+v, wanted %+v", test.line, spans, expected)
+			}
+		})
+	}
+}
+
+func TestVariablesConstantsAndTypes(t *testing.T) {
+	p := parser{}
+	spans := p.parseLine("Vt FILE Va stdin Dv NULL")
+	expected := []Span{
+		TextSpan{Typ: TagType, Text: "FILE"},
+		TextSpan{Typ: TagVariable, Text: "stdin"},
+		TextSpan{Typ: TagConstant, Text: "NULL"},
+	}
+	if !slices.Equal(spans, expected) {
+		t.Errorf("%+v did not equal %+v", spans, expected)
+	}
+}
+
+func TestBarePath(t *testing.T) {
+	tests := map[string][]Span{
+		"Pa":          {TextSpan{Typ: TagPath, Text: "~"}},
+		"Pa /usr/ Pa": {TextSpan{Typ: TagPath, Text: "/usr/"}, TextSpan{Typ: TagPath, Text: "~"}},
+	}
+	for line, expected := range tests {
+		p := parser{}
+		if spans := p.parseLine(line); !slices.Equal(spans, expected) {
+			t.Errorf("%s parsed as %+v, wanted %+v", line, spans, expected)
+		}
+	}
+}
+
+func TestMultipleArguments(t *testing.T) {
+	tests := map[string][]Span{
+		"Fl a b c": {FlagSpan{Flag: "a", Dash: true}, FlagSpan{Flag: "b", Dash: true}, FlagSpan{Flag: "c", Dash: true}},
+		"Ar one two Ns ,": {
+			TextSpan{Typ: TagArg, Text: "one"},
+			TextSpan{Typ: TagArg, Text: "two", NoSpace: true},
+			TextSpan{Typ: TagPlain, Text: ","},
+		},
+		"Cm x y Fl z":   {FlagSpan{Flag: "x"}, FlagSpan{Flag: "y"}, FlagSpan{Flag: "z", Dash: true}},
+		"Sy bold words": {TextSpan{Typ: TagSymbolic, Text: "bold"}, TextSpan{Typ: TagSymbolic, Text: "words"}},
+	}
+	for line, expected := range tests {
+		p := parser{}
+		if spans := p.parseLine(line); !slices.Equal(spans, expected) {
+			t.Errorf("%s parsed as %+v, wanted %+v", line, spans, expected)
+		}
+	}
+}
+
+func TestClosingPunctuation(t *testing.T) {
+	for _, mark := range []string{".", ",", ";", ":", ")", "]", "?", "!"} {
+		p := parser{}
+		spans := p.parseLine("Ar file " + mark)
+		if res := (Section{Contents: spans}).Render(80, DefaultOptions()); res != "file"+mark {
+			t.Errorf("Ar file %s rendered as %q", mark, res)
+		}
+	}
+
+	p := parser{}
+	spans := p.parseLine("Ar src , dst .")
+	if res := (Section{Contents: spans}).Render(80, DefaultOptions()); res != "src, dst." {
+		t.Errorf("rendered %q", res)
+	}
+	if arg := spans[2]; arg != (TextSpan{TagArg, "dst", true}) {
+		t.Errorf("argument after the comma = %+v", arg)
+	}
+}
+
+func TestOpeningPunctuation(t *testing.T) {
+	tests := map[string]string{
+		"( Ar x )":           "(x)",
+		"Ar file [ Ar n ]":   "file [n]",
+		"Fl o ( Ar opts ) .": "-o (opts).",
+	}
+	for line, expected := range tests {
+		p := parser{}
+		if res := (Section{Contents: p.parseLine(line)}).Render(80, DefaultOptions()); res != expected {
+			t.Errorf("%s rendered as %q, wanted %q", line, res, expected)
+		}
+	}
+}
+
+func TestEnvironmentVariables(t *testing.T) {
+	p := parser{}
+	spans := p.parseLine("Ev HOME PATH Ar dir Ev TERM")
+	expected := []Span{
+		TextSpan{Typ: TagEnvVar, Text: "HOME"},
+		TextSpan{Typ: TagEnvVar, Text: "PATH"},
+		TextSpan{Typ: TagArg, Text: "dir"},
+		TextSpan{Typ: TagEnvVar, Text: "TERM"},
+	}
+	if !slices.Equal(spans, expected) {
+		t.Errorf("%+v did not equal %+v", spans, expected)
+	}
+	if res := (Section{Contents: spans}).Render(80, DefaultOptions()); res != "$HOME $PATH dir $TERM" {
+		t.Errorf("rendered %q", res)
+	}
+}
+
+func TestInlineFunctionsAndConstants(t *testing.T) {
+	p := parser{}
+	spans := p.parseLine(`Fn open "const char *path" flags Dv O_RDONLY .`)
+	expected := []Span{
+		TextSpan{TagFunction, "open", true},
+		TextSpan{TagPlain, "(", true},
+		TextSpan{TagArg, "const char *path", true},
+		TextSpan{TagPlain, ",", false},
+		TextSpan{TagArg, "flags", true},
+		TextSpan{TagPlain, ")", false},
+		TextSpan{TagConstant, "O_RDONLY", true},
+		TextSpan{TagPlain, ".", false},
+	}
+	if !slices.Equal(spans, expected) {
+		t.Errorf("%+v did not equal %+v", spans, expected)
+	}
+	if res := (Section{Contents: spans}).Render(80, DefaultOptions()); res != "open(const char *path, flags) O_RDONLY." {
+		t.Errorf("rendered %q", res)
+	}
+	if res := (Section{Contents: p.parseLine("Fn getpid ,")}).Render(80, DefaultOptions()); res != "getpid()," {
+		t.Errorf("rendered %q", res)
+	}
+}
+
+func TestParseMs(t *testing.T) {
+	p := parser{}
+	spans := p.parseLine("Ms alpha")
+	expected := []Span{TextSpan{Typ: TagSymbolic, Text: "alpha"}}
+	if !slices.Equal(spans, expected) {
+		t.Errorf("%+v did not equal %+v", spans, expected)
+	}
+}
+
+func TestParseFd(t *testing.T) {
+	doc := ".Sh SYNOPSIS\n.Fd #define FOO 1\n.Fd #include \"bar.h\""
+	p := parser{}
+	page := p.parseMdoc(doc)
+	expected := []Span{
+		TextSpan{TagBold, "#define FOO 1", false},
+		TextSpan{TagPlain, "\n", true},
+		TextSpan{TagBold, `#include "bar.h"`, false},
+		TextSpan{TagPlain, "\n", true},
+	}
+	if !slices.Equal(page.Sections[0].Contents, expected) {
+		t.Errorf("%+v did not equal %+v", page.Sections[0].Contents, expected)
+	}
+}
+
+func TestLibraryRef(t *testing.T) {
+	tests := []struct {
+		line     string
+		rendered string
+	}{
+		{"Lb libc", "Standard C Library (libc, -lc)"},
+		{"Lb libm", "Math Library (libm, -lm)"},
+		{"Lb libfoo", "libfoo"},
+		{"Lb libc Ns ,", "Standard C Library (libc, -lc),"},
+		{"Lb libm and", "Math Library (libm, -lm) and"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			p := parser{}
+			if rendered := (Section{Contents: p.parseLine(test.line)}).Render(80, DefaultOptions()); rendered != test.rendered {
+				t.Errorf("%q rendered as %q, wanted %q", test.line, rendered, test.rendered)
+			}
+		})
+	}
+}
+
+func TestFontEscapes(t *testing.T) {
+	p := parser{}
+	spans := p.parseLine(`\f(CWcode\fR plain \f[B]bold\f[] \f[XYZ]unknown`)
+	expected := []Span{
+		TextSpan{Typ: TagLiteral, Text: "code"},
+		TextSpan{Typ: TagPlain, Text: "plain"},
+		TextSpan{Typ: TagBold, Text: "bold"},
+		TextSpan{Typ: TagPlain, Text: "unknown"},
+	}
+	if !slices.Equal(spans, expected) {
+		t.Errorf("%+v did not equal %+v", spans, expected)
+	}
+}
+
+func TestNumericFontEscapes(t *testing.T) {
+	p := parser{}
+	spans := p.parseLine(`\f1roman \f2italic \f3bold \f4both`)
+	expected := []Span{
+		TextSpan{Typ: TagPlain, Text: "roman"},
+		TextSpan{Typ: TagItalic, Text: "italic"},
+		TextSpan{Typ: TagBold, Text: "bold"},
+		TextSpan{Typ: TagBoldItalic, Text: "both"},
+	}
+	if !slices.Equal(spans, expected) {
+		t.Errorf("%+v did not equal %+v", spans, expected)
+	}
+}
+
+func TestJoinContinuations(t *testing.T) {
+	input := []string{
+		".Fl a Fl b \\",
+		"Fl c",
+		"split wo\\c",
+		"rd",
+		".B bold\\c",
+		".I italic",
+		`escaped backslash \\`,
+		"end",
+	}
+	var lines []string
+	var lineNos []int
+	joiner := continuationJoiner{next: collectLines(&lines, &lineNos)}
+	for i, line := range input {
+		joiner.add(line, i)
+	}
+	joiner.flush()
+	expected := []string{
+		".Fl a Fl b Fl c",
+		"split word",
+		".B bold",
+		".I italic",
+		`escaped backslash \\`,
+		"end",
+	}
+	if !slices.Equal(lines, expected) {
+		t.Errorf("joined lines %q, wanted %q", lines, expected)
+	}
+	if expectedNos := []int{0, 2, 4, 5, 6, 7}; !slices.Equal(lineNos, expectedNos) {
+		t.Errorf("line numbers %v, wanted %v", lineNos, expectedNos)
+	}
+
+	p := parser{}
+	page := p.parseMdoc(".Sh OPTIONS\n.Fl a Fl b \\\nFl c")
+	flags := []Span{FlagSpan{"a", true, false}, FlagSpan{"b", true, false}, FlagSpan{"c", true, false}}
+	if !slices.Equal(page.Sections[0].Contents, flags) {
+		t.Errorf("%+v did not equal %+v", page.Sections[0].Contents, flags)
+	}
+}
+
+func TestMultiLineOptional(t *testing.T) {
+	doc := `.Sh SYNOPSIS
+.Nm tar
+.Oo
+.Fl f
+.Ar archive
+.Oc
+.Op Fl v Oo Fl z Oc
+.Ar file`
+	p := parser{}
+	page := p.parseMdoc(doc)
+	expected := []Span{
+		TextSpan{Typ: TagNameRef, Text: "tar"},
+		DecoratedSpan{DecorationOptional, []Span{
+			FlagSpan{"f", true, false},
+			TextSpan{Typ: TagArg, Text: "archive"},
+		}, false},
+		DecoratedSpan{DecorationOptional, []Span{
+			FlagSpan{"v", true, false},
+			DecoratedSpan{DecorationOptional, []Span{FlagSpan{"z", true, false}}, false},
+		}, false},
+		TextSpan{Typ: TagArg, Text: "file"},
+	}
+	if !reflect.DeepEqual(page.Sections[0].Contents, expected) {
+		t.Errorf("%+v did not equal %+v", page.Sections[0].Contents, expected)
+	}
+	// a decoration is on the line it was opened on
+	if lines := page.Sections[0].Lines; !slices.Equal(lines, []int{2, 3, 7, 8}) {
+		t.Errorf("lines = %v", lines)
+	}
+}
+
+func TestUnclosedOptional(t *testing.T) {
+	doc := ".Sh SYNOPSIS\n.Oo\n.Fl a\n.Sh DESCRIPTION\ntext"
+	p := parser{}
+	page := p.parseMdoc(doc)
+	expected := []Span{DecoratedSpan{DecorationOptional, []Span{FlagSpan{"a", true, false}}, false}}
+	if !reflect.DeepEqual(page.Sections[0].Contents, expected) {
+		t.Errorf("%+v did not equal %+v", page.Sections[0].Contents, expected)
+	}
+}
+
+func TestBoilerplate(t *testing.T) {
+	doc := `.Sh NAME
+.Nm frob
+.Sh DESCRIPTION
+This program
+.Bt
+.Ex -std
+.Rv -std frob_init`
+	p := parser{}
+	page := p.parseMdoc(doc)
+	expected := []Span{
+		TextSpan{Typ: TagPlain, Text: "This"},
+		TextSpan{Typ: TagPlain, Text: "program"},
+		TextSpan{Typ: TagPlain, Text: "is currently in beta test."},
+		TextSpan{Typ: TagPlain, Text: "The frob utility exits 0 on success, and >0 if an error occurs."},
+		TextSpan{Typ: TagPlain, Text: "The frob_init() function returns the value 0 if successful; otherwise the value -1 is returned and the global variable errno is set to indicate the error."},
+	}
+	if !slices.Equal(page.Sections[1].Contents, expected) {
+		t.Errorf("%+v did not equal %+v", page.Sections[1].Contents, expected)
+	}
+}
+
+func TestAdjustMode(t *testing.T) {
+	doc := ".Sh DESCRIPTION\nragged\n.ad\njustified\n.na\nragged again\n.ad b\nand\n.ad l\nleft"
+	p := parser{}
+	page := p.parseMdoc(doc)
+	expected := []Span{
+		TextSpan{TagPlain, "ragged", false},
+		&Block{Contents: []Span{TextSpan{TagPlain, "justified", false}}, Lines: []int{4}, Justify: true},
+		TextSpan{TagPlain, "ragged", false},
+		TextSpan{TagPlain, "again", false},
+		&Block{Contents: []Span{TextSpan{TagPlain, "and", false}}, Lines: []int{8}, Justify: true},
+		TextSpan{TagPlain, "left", false},
+	}
+	if !reflect.DeepEqual(page.Sections[0].Contents, expected) {
+		t.Errorf("%#v did not equal %#v", page.Sections[0].Contents, expected)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"$Mdocdate: July 4 2020 $", "July 4, 2020"},
+		{"$Mdocdate: March 31 2022 $", "March 31, 2022"},
+		{"July 4, 2020", "July 4, 2020"},
+		{"Jul 4, 2020", "July 4, 2020"},
+		{"2020-07-04", "July 4, 2020"},
+		{"$Mdocdate$", ""},
+		{"Summer 2020", "Summer 2020"},
+	}
+	for _, test := range tests {
+		if res := parseDate(test.input); res != test.expected {
+			t.Errorf("parseDate(%q) = %q, wanted %q", test.input, res, test.expected)
+		}
+	}
+
+	p := parser{}
+	page := p.parseMdoc(".Dd $Mdocdate: July 4 2020 $\n.Sh NAME")
+	if page.Date != "July 4, 2020" {
+		t.Errorf("date = %q", page.Date)
+	}
+}
+
+func TestStripComment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`.Fl a \" the a flag`, ".Fl a"},
+		{`.\" whole line`, "."},
+		{`'\" t`, "'"},
+		{`text\"comment`, "text"},
+		{`a \\" quote`, `a \\" quote`},
+		{`a \\\" comment`, `a \\`},
+		{`no comment`, "no comment"},
+	}
+	for _, test := range tests {
+		if res := stripComment(test.input); res != test.expected {
+			t.Errorf("stripComment(%q) = %q, wanted %q", test.input, res, test.expected)
+		}
+	}
+
+	p := parser{}
+	page := p.parseMdoc(".Sh OPTIONS\n.Fl v \\\" verbose\n\\\" just a comment\ntext")
+	expected := []Span{FlagSpan{"v", true, false}, TextSpan{Typ: TagPlain, Text: "text"}}
+	if !reflect.DeepEqual(page.Sections[0].Contents, expected) {
+		t.Errorf("%+v did not equal %+v", page.Sections[0].Contents, expected)
+	}
+}
+
+func TestContentBeforeSection(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc("some text\n.Sh NOTES\nmore")
+	if len(page.Sections) != 2 || page.Sections[0].Name != "DESCRIPTION" || page.Sections[1].Name != "NOTES" {
+		t.Fatalf("sections = %+v", page.Sections)
+	}
+	expected := []Span{TextSpan{Typ: TagPlain, Text: "some"}, TextSpan{Typ: TagPlain, Text: "text"}}
+	if !slices.Equal(page.Sections[0].Contents, expected) {
+		t.Errorf("%+v did not equal %+v", page.Sections[0].Contents, expected)
+	}
+
+	if page := p.parseMdoc(""); len(page.Sections) != 0 {
+		t.Errorf("empty page has sections %+v", page.Sections)
+	}
+}
+
+func TestIndentedParagraphArgs(t *testing.T) {
+	for _, macro := range []string{`.IP \fB-a\fR 4`, `.IP "\fB-a\fP" 4n`, `.IP -a .4i`, `.IP -a 4`} {
+		p := parser{}
+		page := p.parseMdoc(".SH OPTIONS\n" + macro + "\nall")
+		expected := []Span{
+			TextSpan{TagPlain, "\n\n        -a", false},
+			TextSpan{TagPlain, "all", false},
+		}
+		if !reflect.DeepEqual(page.Sections[0].Contents, expected) {
+			t.Errorf("%s parsed as %#v", macro, page.Sections[0].Contents)
+		}
+	}
+
+	// an indent that isn't a length is ignored
+	p := parser{}
+	page := p.parseMdoc(".SH OPTIONS\n.IP -a wide\nall")
+	if res := page.Sections[0].Render(80, DefaultOptions()); res != "-a all" {
+		t.Errorf("rendered %q", res)
+	}
+}
+
+func TestOverstrikeInSource(t *testing.T) {
+	p := parser{}
+	spans := p.parseLine("a b\bbo\bol\bld\bd and _\bu_\bn_\bdone word")
+	expected := []Span{
+		TextSpan{TagPlain, "a", false},
+		TextSpan{TagBold, "bold", false},
+		TextSpan{TagPlain, "and", false},
+		TextSpan{TagUnderline, "und", true},
+		TextSpan{TagPlain, "one", false},
+		TextSpan{TagPlain, "word", false},
+	}
+	if !reflect.DeepEqual(spans, expected) {
+		t.Errorf("%#v did not equal %#v", spans, expected)
+	}
+
+	if isCatPage(".TH LS 1\n.SH NAME\nb\bbold\n") {
+		t.Error("source page with overstrike was detected as a cat page")
+	}
+}
+
+func TestZeroWidthSpace(t *testing.T) {
+	p := parser{}
+	spans := p.parseLine(`Ar foo\&bar No \&Fl x`)
+	expected := []Span{
+		TextSpan{TagArg, "foobar", false},
+		TextSpan{TagPlain, "Fl", false},
+		TextSpan{TagPlain, "x", false},
+	}
+	if !reflect.DeepEqual(spans, expected) {
+		t.Errorf("%#v did not equal %#v", spans, expected)
+	}
+
+	page := p.parseMdoc(".Sh DESCRIPTION\n\\&.Nm is text\n")
+	if res := page.Sections[0].Render(80, DefaultOptions()); res != ".Nm is text" {
+		t.Errorf("rendered %q, wanted %q", res, ".Nm is text")
+	}
+}
+
+func TestZeroWidthSpaceInNames(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh FOO\\&BAR\n.Ss Sub\\&section\n.Xr foo\\&bar 1")
+	if name := page.Sections[0].Name; name != "FOOBAR" {
+		t.Errorf("section named %q", name)
+	}
+	expected := []Span{
+		TextSpan{TagSubsectionHeader, "Subsection", true},
+		ManRef{"foobar", "1", false},
+	}
+	if !reflect.DeepEqual(page.Sections[0].Contents, expected) {
+		t.Errorf("%#v did not equal %#v", page.Sections[0].Contents, expected)
+	}
+}
+
+func TestBraces(t *testing.T) {
+	doc := `.Sh SYNOPSIS
+.Nm ls
+.Brq Fl a | Fl b
+.Bro
+.Fl c | Oo Fl d Oc
+.Brc`
+	p := parser{}
+	page := p.parseMdoc(doc)
+	if res := page.Sections[0].Render(80, DefaultOptions()); res != "ls {-a | -b} {-c | [-d]}" {
+		t.Errorf("rendered %q", res)
+	}
+}
+
+func TestNonNumericSections(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Dt PRINTF 3p\n.Sh SEE ALSO\n.Xr printf 3p\n.Xr Tcl n\n.Xr intro")
+	if page.Section != "3p" {
+		t.Errorf("section = %q, wanted 3p", page.Section)
+	}
+	expected := []Span{ManRef{"printf", "3p", false}, ManRef{"Tcl", "n", false}, ManRef{"intro", "", false}}
+	if !reflect.DeepEqual(page.Sections[0].Contents, expected) {
+		t.Errorf("%+v did not equal %+v", page.Sections[0].Contents, expected)
+	}
+	if res := (ManRef{"printf", "3p", true}).Render(80, DefaultOptions()); res != "printf(3p)" {
+		t.Errorf("rendered %q", res)
+	}
+
+	page = p.parseMdoc(".TH after n 2020-01-01\n.SH NAME\nafter")
+	if page.Section != "n" {
+		t.Errorf("section = %q, wanted n", page.Section)
+	}
+}
+
+func TestMdocTitle(t *testing.T) {
+	tests := []struct {
+		line                 string
+		name, section        string
+		manual, arch, header string
+	}{
+		{".Dt FOO 9 i386", "FOO", "9", "Kernel Developer's Manual", "i386", "FOO(9) Kernel Developer's Manual (i386) FOO(9)"},
+		{".Dt BAR 3p", "BAR", "3p", "Library Functions Manual", "", "BAR(3p) Library Functions Manual BAR(3p)"},
+		{".Dt X11-TOOL 1 LOCAL", "X11-TOOL", "1", "Local Manual", "", "X11-TOOL(1) Local Manual X11-TOOL(1)"},
+	}
+	for _, test := range tests {
+		p := parser{}
+		page := p.parseMdoc(test.line + "\n.Sh NAME\nfoo")
+		if page.Name != test.name || page.Section != test.section || page.Manual != test.manual || page.Arch != test.arch {
+			t.Errorf("%s parsed as %+v", test.line, page)
+		}
+		if header := strings.Join(strings.Fields(page.header(80)), " "); header != test.header {
+			t.Errorf("%s has the header %q, wanted %q", test.line, header, test.header)
+		}
+	}
+}
+
+func TestManTitle(t *testing.T) {
+	tests := []struct {
+		line                string
+		name, section, date string
+		source, manual      string
+	}{
+		{`.TH FOO 3pm "2023-05-01" "perl v5.36.0" "Perl Programmers Reference Guide"`, "FOO", "3pm", "2023-05-01", "perl v5.36.0", "Perl Programmers Reference Guide"},
+		{`.TH LS 1 2024-01-01 "GNU coreutils 9.4"`, "LS", "1", "2024-01-01", "GNU coreutils 9.4", ""},
+		{".TH BAR 1", "BAR", "1", "", "", ""},
+		{".TH BAZ", "BAZ", "", "", "", ""},
+	}
+	for _, test := range tests {
+		p := parser{}
+		page := p.parseMdoc(test.line + "\n.SH NAME\nfoo")
+		if page.Name != test.name || page.Section != test.section || page.Date != test.date ||
+			page.Source != test.source || page.Manual != test.manual {
+			t.Errorf("%s parsed as %+v", test.line, page)
+		}
+	}
+}
+
+func TestAlternatingFonts(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected []Span
+	}{
+		{"BR ls (1)", []Span{TextSpan{TagBold, "ls", true}, TextSpan{TagPlain, "(1)", false}}},
+		{"BR a b c", []Span{TextSpan{TagBold, "a", true}, TextSpan{TagPlain, "b", true}, TextSpan{TagBold, "c", false}}},
+		{"IR file ,", []Span{TextSpan{TagItalic, "file", true}, TextSpan{TagPlain, ",", false}}},
+		{`RI "see " page`, []Span{TextSpan{TagPlain, "see ", true}, TextSpan{TagItalic, "page", false}}},
+		{"RB x", []Span{TextSpan{TagPlain, "x", false}}},
+		{`IR "" file`, []Span{TextSpan{TagPlain, "file", false}}},
+		{`BR a "" c d`, []Span{TextSpan{TagBold, "a", true}, TextSpan{TagBold, "c", true}, TextSpan{TagPlain, "d", false}}},
+		{"BR a  b", []Span{TextSpan{TagBold, "a", true}, TextSpan{TagPlain, "b", false}}},
+		{"BR", nil},
+	}
+	for _, test := range tests {
+		p := parser{}
+		if spans := p.parseLine(test.line); !reflect.DeepEqual(spans, test.expected) {
+			t.Errorf("parseLine(%q) = %#v, wanted %#v", test.line, spans, test.expected)
+		}
+	}
+
+	p := parser{}
+	page := p.parseMdoc(".SH SEE ALSO\n.BR ls (1),\n.BR cp (1)")
+	if res := page.Sections[0].Render(80, DefaultOptions()); res != "ls(1), cp(1)" {
+		t.Errorf("rendered %q", res)
+	}
+}
+
+func TestManLinks(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(`.SH BUGS
+Report bugs at
+.UR https://example.com/bugs
+the tracker
+.UE .
+Or mail
+.MT bugs@example.com
+.ME
+directly.
+.UR https://example.com
+.UE`)
+	expected := []Span{
+		TextSpan{TagPlain, "Report", false},
+		TextSpan{TagPlain, "bugs", false},
+		TextSpan{TagPlain, "at", false},
+		LinkSpan{"https://example.com/bugs", []Span{TextSpan{TagPlain, "the", false}, TextSpan{TagPlain, "tracker", false}}, true},
+		TextSpan{TagPlain, ".", false},
+		TextSpan{TagPlain, "Or", false},
+		TextSpan{TagPlain, "mail", false},
+		LinkSpan{"mailto:bugs@example.com", nil, false},
+		TextSpan{TagPlain, "directly.", false},
+		LinkSpan{"https://example.com", nil, false},
+	}
+	if !reflect.DeepEqual(page.Sections[0].Contents, expected) {
+		t.Errorf("%#v did not equal %#v", page.Sections[0].Contents, expected)
+	}
+
+	page.mergeSpans()
+	res := Wrap(page.Sections[0].Render(80, DefaultOptions()), 80)
+	if expected := "Report bugs at the tracker ⟨https://example.com/bugs⟩. Or mail bugs@example.com\ndirectly. https://example.com"; res != expected {
+		t.Errorf("rendered %q, wanted %q", res, expected)
+	}
+}
+
+func TestBareIndentedParagraph(t *testing.T) {
+	for _, macro := range []string{".IP", `.IP ""`, ".P", ".LP", ".PP"} {
+		for distance, gap := range map[string]string{"": "\n\n", ".PD 0\n": "\n"} {
+			p := parser{}
+			page := p.parseMdoc(".SH DESCRIPTION\n" + distance + "first\n" + macro + "\nsecond")
+			expected := []Span{
+				TextSpan{TagPlain, "first", false},
+				TextSpan{TagPlain, gap, false},
+				TextSpan{TagPlain, "second", false},
+			}
+			if !reflect.DeepEqual(page.Sections[0].Contents, expected) {
+				t.Errorf("%q then %s parsed as %#v", distance, macro, page.Sections[0].Contents)
+			}
+		}
+	}
+}
+
+func TestMultipleNames(t *testing.T) {
+	for _, doc := range []string{
+		".Sh NAME\n.Nm gzip ,\n.Nm gunzip ,\n.Nm zcat\n.Nd compress files",
+		".Sh NAME\n.Nm gzip\n.Nm gunzip\n.Nm zcat\n.Nd compress files",
+	} {
+		p := parser{}
+		page := p.parseMdoc(doc + "\n.Sh SYNOPSIS\n.Nm\n.Op Fl d")
+		if res := page.Sections[0].Render(80, DefaultOptions()); res != "gzip, gunzip, zcat – compress files" {
+			t.Errorf("rendered NAME as %q", res)
+		}
+		if res := page.Sections[1].Render(80, DefaultOptions()); res != "gzip [-d]" {
+			t.Errorf("rendered SYNOPSIS as %q", res)
+		}
+	}
+}
+
+func TestSectionNameSpacing(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".TH FOO 1\n.SH   NAME  \nfoo \\- bar\n.SH \"SEE   ALSO\"\n.SS  Other   pages \nbaz\n.Sh synopsis\n.In stdio.h\ntext")
+	var names []string
+	for _, section := range page.Sections {
+		names = append(names, section.Name)
+	}
+	if !slices.Equal(names, []string{"NAME", "SEE ALSO", "synopsis"}) {
+		t.Errorf("section names = %q", names)
+	}
+	if header := page.Sections[1].Contents[0]; header != (TextSpan{TagSubsectionHeader, "Other pages", true}) {
+		t.Errorf("subsection header = %+v", header)
+	}
+	if res := trimLines(page.Sections[2].Render(80, DefaultOptions())); res != "#include <stdio.h>\n\ntext" {
+		t.Errorf("lowercase synopsis rendered as %q", res)
+	}
+}
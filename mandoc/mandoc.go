@@ -0,0 +1,66 @@
+// Package mandoc parses man pages written with the mdoc or man macros, or
+// preformatted cat pages, and renders them for the terminal.
+package mandoc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// How much of a page Parse looks at to tell a cat page from source.
+const catPagePeek = 64 * 1024
+
+// A Format is the macro set a page is written in. Left to itself, the parser
+// understands both, whichever a page uses.
+type Format int
+
+const (
+	FormatAuto Format = iota // either macro set, or a cat page, told apart by looking at the page
+	FormatMdoc               // BSD mdoc macros
+	FormatMan                // the older man macros
+)
+
+// ParseFormat reads a format name given by the user: auto, mdoc, or man.
+func ParseFormat(name string) (Format, error) {
+	switch name {
+	case "auto":
+		return FormatAuto, nil
+	case "mdoc":
+		return FormatMdoc, nil
+	case "man":
+		return FormatMan, nil
+	}
+	return FormatAuto, fmt.Errorf("unknown format %q", name)
+}
+
+// Parse a man page written in the given format as it's read from r, without
+// holding the whole source in memory. The page must already be decompressed
+// and decoded to UTF-8. With FormatAuto, cat pages are recognized from the
+// start of the input, and are read in full. Forcing mdoc or man reads the page
+// as source, and only the macros of that set are understood, so words like No
+// or Ar in man text aren't taken for mdoc macros. Pages the parser can't make
+// sense of are returned as an error.
+func Parse(r io.Reader, format Format) (*Page, error) {
+	br := bufio.NewReaderSize(r, catPagePeek)
+	prefix, err := br.Peek(catPagePeek)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	var parsed Page
+	if format == FormatAuto && isCatPage(string(prefix)) {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		parsed = parseCatPage(string(data))
+	} else {
+		parser := parser{format: format}
+		if parsed, err = parser.parseReader(br); err != nil {
+			return nil, err
+		}
+	}
+	parsed.mergeSpans()
+	return &parsed, nil
+}
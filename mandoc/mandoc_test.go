@@ -0,0 +1,110 @@
+package mandoc
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	page, err := Parse(strings.NewReader(".Dt LS 1\n.Sh NAME\n.Nm ls\n.Nd list directory contents"), FormatAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Name != "LS" || page.Section != "1" || len(page.Sections) != 1 {
+		t.Errorf("parsed %+v", page)
+	}
+
+	if _, err := Parse(strings.NewReader(".Dt LS 1\n.Sh NAME\n.Bl -tag \"x\n"), FormatAuto); err == nil {
+		t.Error("expected an error for an unterminated quote")
+	}
+
+	// roff ends an unbalanced quote at the end of the line
+	page, err = Parse(strings.NewReader(".TH LS 1 2024 \"GNU coreutils\n.SH NAME\n"), FormatAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Name != "LS" || page.Source != "GNU coreutils" {
+		t.Errorf("parsed %+v", page)
+	}
+}
+
+func TestParseStream(t *testing.T) {
+	tests := []struct {
+		doc      string
+		section  string
+		rendered string
+	}{
+		{"\ufeff.Dt LS 1\r\n.Sh NAME\r\n.Nm ls\r\n.Nd list \\\r\ndirectory contents\r\n", "NAME", "ls – list directory contents"},
+		{".de XX\n.Sh \\$1\n..\n.XX DESCRIPTION\n.if n \\{\\\nterminal\n.\\}\ntext \\\" comment\n", "DESCRIPTION", "terminal text"},
+		{"NAME\n     ls - list directory contents\n", "NAME", "ls - list directory contents"},
+	}
+	for _, test := range tests {
+		page, err := Parse(strings.NewReader(test.doc), FormatAuto)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Sections) != 1 || page.Sections[0].Name != test.section {
+			t.Errorf("Parse(%q) = %+v, wanted one %s section", test.doc, page, test.section)
+			continue
+		}
+		if res := page.Sections[0].Render(80, DefaultOptions()); res != test.rendered {
+			t.Errorf("Parse(%q) rendered %q, wanted %q", test.doc, res, test.rendered)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	// no title and an overstruck word, so it looks like a cat page
+	doc := ".SH NAME\nfrob \\- f\bfr\bro\bob\bb things\n"
+	page, err := Parse(strings.NewReader(doc), FormatAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Sections) == 1 && page.Sections[0].Name == "NAME" {
+		t.Errorf("auto detection parsed the source: %+v", page)
+	}
+
+	page, err = Parse(strings.NewReader(doc), FormatMan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Sections) != 1 || page.Sections[0].Name != "NAME" {
+		t.Errorf("forcing man gave %+v", page)
+	}
+
+	// mdoc macro names are words in man text, and man macros are unknown in mdoc
+	man := ".TH FROB 1\n.SH NAME\nNo more Ar here\n.B Fl x\n.Sh OTHER"
+	page, err = Parse(strings.NewReader(man), FormatMan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := page.Sections[0].Render(80, DefaultOptions()); res != "No more Ar here Fl x Sh OTHER" {
+		t.Errorf("forcing man rendered %q", res)
+	}
+	if expected := []Warning{{Line: 5, Macro: "Sh"}}; !slices.Equal(page.Warnings, expected) {
+		t.Errorf("forcing man warned %+v, wanted %+v", page.Warnings, expected)
+	}
+	page, err = Parse(strings.NewReader(".Dt FROB 1\n.Sh DESCRIPTION\n.Op Fl v\n.SH OTHER"), FormatMdoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Sections) != 1 || page.Sections[0].Render(80, DefaultOptions()) != "[-v] SH OTHER" {
+		t.Errorf("forcing mdoc gave %+v", page)
+	}
+
+	if _, err := ParseFormat("troff"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestStandardExpansion(t *testing.T) {
+	page, err := Parse(strings.NewReader(".Dt LS 1\n.Sh STANDARDS\nThe\n.Nm\nutility conforms to\n.St -susv3 .\n"), FormatAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `utility conforms to Version 3 of the Single UNIX Specification (“SUSv3”).`
+	if res := page.Render(200, DefaultOptions()); !strings.Contains(res, expected) {
+		t.Errorf("rendered %q, wanted it to contain %q", res, expected)
+	}
+}
@@ -0,0 +1,902 @@
+package mandoc
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+type Span interface {
+	Render(width int, opts Options) string
+}
+
+// Options change how pages are rendered. Start from DefaultOptions.
+type Options struct {
+	TabWidth          int         // width of tab stops in preformatted text
+	TwoColumns        bool        // show running text in two columns in wide windows
+	TwoColumnMinWidth int         // narrowest width to use two columns in
+	HighlightExamples bool        // color what look like shell commands in EXAMPLES sections
+	Markers           ListMarkers // markers in front of list items
+	DescriptionDash   string      // between the names and the description in NAME
+	Hyperlinks        bool        // make links clickable with OSC 8 escapes instead of showing their address
+}
+
+func DefaultOptions() Options {
+	return Options{
+		TabWidth:          8,
+		TwoColumnMinWidth: 160,
+		Markers:           DefaultMarkers,
+		DescriptionDash:   "–",
+	}
+}
+
+var sectionHeader = lipgloss.NewStyle().
+	Bold(true).
+	BorderStyle(lipgloss.RoundedBorder()).
+	BorderBottom(true)
+
+func (page Page) Render(width int, opts Options) string {
+	res := ""
+	if header := page.header(width); header != "" {
+		res += header + "\n\n"
+	}
+	for i, section := range page.Sections {
+		if i != 0 {
+			res += "\n\n"
+		}
+		res += fmt.Sprintf("%s\n", sectionHeader.Render(section.Name))
+
+		if opts.TwoColumns && width >= opts.TwoColumnMinWidth && section.isProse() {
+			res += renderColumns(section, width, opts)
+		} else {
+			res += section.Render(width, opts)
+		}
+	}
+	if footer := page.footer(width); footer != "" {
+		res += lipgloss.NewStyle().Border(lipgloss.NormalBorder(), true, false, false, false).Margin(2, 0).Render(footer)
+	}
+	return res
+}
+
+// The top line of the page, like man's "LS(1)  User Commands  LS(1)". Pages
+// without a manual title have no header.
+func (page Page) header(width int) string {
+	if page.Manual == "" {
+		return ""
+	}
+	title := fmt.Sprintf("%s(%s)", page.Name, page.Section)
+	manual := page.Manual
+	if page.Arch != "" {
+		manual += fmt.Sprintf(" (%s)", page.Arch)
+	}
+	return spread(title, manual, title, width)
+}
+
+// The bottom line of the page, like man's "BSD  July 4, 2020  BSD", or
+// "GNU coreutils 9.4  July 4, 2020  LS(1)" for man pages with a source.
+func (page Page) footer(width int) string {
+	switch {
+	case page.OS != "":
+		return spread(page.OS, page.Date, page.OS, width)
+	case page.Source != "":
+		return spread(page.Source, page.Date, fmt.Sprintf("%s(%s)", page.Name, page.Section), width)
+	default:
+		return page.Date
+	}
+}
+
+// spread lays out left, center and right across width, dropping the
+// repeated right-hand side when they don't fit.
+func spread(left, center, right string, width int) string {
+	gap := width - lipgloss.Width(left) - lipgloss.Width(center) - lipgloss.Width(right)
+	if gap < 2 {
+		return left + " " + center
+	}
+	half := gap / 2
+	return left + strings.Repeat(" ", half) + center + strings.Repeat(" ", gap-half) + right
+}
+
+func (section Section) Render(width int, opts Options) string {
+	contents := ""
+	for _, content := range section.Contents {
+		if _, ok := content.(*Block); ok && contents != "" && !strings.HasSuffix(contents, "\n") {
+			contents += "\n"
+		}
+		if line, ok := content.(LiteralLine); ok && opts.HighlightExamples && section.is("EXAMPLES") {
+			content = LiteralLine{highlightCommand(line.Contents)}
+		}
+		contents += content.Render(width, opts)
+	}
+	return strings.TrimSpace(fillTabs(contents))
+}
+
+// Wrap the block to its line length, which later wrapping to the window leaves
+// alone, and justify it if it's adjusted. Text after it carries on from its
+// last line.
+func (b *Block) Render(width int, opts Options) string {
+	if b.LineLength > 0 {
+		width = min(width, b.LineLength)
+	}
+	contents := ""
+	for _, span := range b.Contents {
+		contents += span.Render(width, opts)
+	}
+	contents = ansi.Wordwrap(strings.TrimRight(fillTabs(contents), " "), width, "")
+	if b.Justify {
+		contents = justify(contents, width)
+	}
+	if contents == "" || strings.HasSuffix(contents, "\n") {
+		return contents
+	}
+	return contents + " "
+}
+
+// Columns are separated by this many spaces.
+const columnGap = 4
+
+// Whether a section is only running text, without lists, tables, literal
+// lines, or a synopsis that need the full width.
+func (section Section) isProse() bool {
+	if section.is("SYNOPSIS") {
+		return false
+	}
+	for _, span := range section.Contents {
+		switch span.(type) {
+		case List, *List, LiteralLine, CenteredLine, UnfilledLine:
+			return false
+		}
+	}
+	return true
+}
+
+// Render a section as two balanced columns, newspaper style.
+func renderColumns(section Section, width int, opts Options) string {
+	columnWidth := (width - columnGap) / 2
+	lines := strings.Split(Wrap(section.Render(columnWidth, opts), columnWidth), "\n")
+	split := (len(lines) + 1) / 2
+
+	left := lipgloss.NewStyle().Width(columnWidth + columnGap).Render(strings.Join(lines[:split], "\n"))
+	right := strings.Join(lines[split:], "\n")
+	return lipgloss.JoinHorizontal(lipgloss.Top, left, right)
+}
+
+// Spread out the words of each line in s to fill width, like .ad b. The last
+// line of each paragraph, and lines with tabs, are left alone.
+func justify(s string, width int) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		endOfParagraph := i == len(lines)-1 || strings.TrimSpace(lines[i+1]) == "" || indentOf(lines[i+1]) != indentOf(line)
+		if endOfParagraph || strings.Contains(line, "\t") || strings.Contains(line, noBreakSpace) {
+			continue
+		}
+		lines[i] = justifyLine(line, width)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func indentOf(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " "))]
+}
+
+var spaces = regexp.MustCompile(` +`)
+
+// Widen the gaps between the words of line so it fills width. Gaps wider than
+// one space stay wider, so spacing the author asked for is kept.
+func justifyLine(line string, width int) string {
+	indent := indentOf(line)
+	text := strings.TrimRight(line[len(indent):], " ")
+	gaps := spaces.FindAllString(text, -1)
+	if len(gaps) == 0 {
+		return line
+	}
+	words := spaces.Split(text, -1)
+
+	extra := width - lipgloss.Width(indent+text)
+	if extra <= 0 {
+		return line
+	}
+
+	var res strings.Builder
+	res.WriteString(indent)
+	for i, word := range words {
+		res.WriteString(word)
+		if i < len(gaps) {
+			// give the extra spaces to the gaps on the right
+			n := extra / len(gaps)
+			if i >= len(gaps)-extra%len(gaps) {
+				n++
+			}
+			res.WriteString(gaps[i] + strings.Repeat(" ", n))
+		}
+	}
+	return res.String()
+}
+
+// Replace tabs with spaces up to the next tab stop. ANSI escape sequences
+// don't take up any columns.
+func expandTabs(s string, tabWidth int) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		var res strings.Builder
+		col := 0
+		for j, field := range strings.Split(line, "\t") {
+			if j > 0 {
+				spaces := tabWidth - col%tabWidth
+				res.WriteString(strings.Repeat(" ", spaces))
+				col += spaces
+			}
+			res.WriteString(field)
+			col += lipgloss.Width(field)
+		}
+		lines[i] = res.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Tabs in filled text are word gaps like any other, since tab stops only mean
+// something in lines kept as they are. Those expand their own tabs first.
+func fillTabs(s string) string {
+	return strings.ReplaceAll(s, "\t", " ")
+}
+
+var allWhitespace, _ = regexp.Compile(`^\s+$`)
+var textStyles = map[TextTag]lipgloss.Style{
+	TagPlain:    lipgloss.NewStyle(),
+	TagNameRef:  lipgloss.NewStyle().Foreground(lipgloss.Color("9")),
+	TagArg:      lipgloss.NewStyle().Foreground(lipgloss.Color("11")),
+	TagVariable: lipgloss.NewStyle().Foreground(lipgloss.Color("13")),
+	TagEnvVar:   lipgloss.NewStyle().Foreground(lipgloss.Color("13")),
+	TagPath:     lipgloss.NewStyle().Foreground(lipgloss.Color("14")),
+	TagSubsectionHeader: lipgloss.NewStyle().
+		Bold(true).
+		Margin(2, 0, 0, 0),
+	TagSymbolic:   lipgloss.NewStyle().Foreground(lipgloss.Color("9")),
+	TagBold:       lipgloss.NewStyle().Bold(true),
+	TagItalic:     lipgloss.NewStyle().Italic(true),
+	TagBoldItalic: lipgloss.NewStyle().Bold(true).Italic(true),
+	TagUnderline:  lipgloss.NewStyle().Underline(true),
+	TagLiteral:    lipgloss.NewStyle(),
+	TagConfig:     lipgloss.NewStyle().Bold(true),
+	TagConstant:   lipgloss.NewStyle().Foreground(lipgloss.Color("6")),
+	TagType:       lipgloss.NewStyle().Foreground(lipgloss.Color("4")),
+	TagFunction:   lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("3")),
+}
+
+func (t TextSpan) Render(_ int, opts Options) string {
+	text := t.Text
+
+	var res string
+	switch t.Typ {
+	case TagEnvVar:
+		res = textStyles[TagEnvVar].Render("$" + text)
+	case TagSingleQuote:
+		res = fmt.Sprintf("'%s'", text)
+	case TagDoubleQuote:
+		res = fmt.Sprintf("\"%s\"", text)
+	case TagSubsectionHeader:
+		res = textStyles[TagSubsectionHeader].Render(text) + "\n"
+	case TagDescriptionDash:
+		return opts.DescriptionDash + " "
+	default:
+		// keep tabs so unfilled lines can expand them to tab stops, and style
+		// each line alone so lipgloss doesn't pad them to one width
+		style := textStyles[t.Typ].TabWidth(lipgloss.NoTabConversion)
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			lines[i] = style.Render(line)
+		}
+		res = strings.Join(lines, "\n")
+	}
+	if !t.NoSpace && !allWhitespace.MatchString(t.Text) && !strings.HasSuffix(t.Text, "\n") {
+		res += " "
+	}
+	return res
+}
+
+var decorationStyles = map[DecorationTag][]string{
+	DecorationOptional:      {"[", "]"},
+	DecorationParens:        {"(", ")"},
+	DecorationSingleQuote:   {"'", "'"},
+	DecorationDoubleQuote:   {"\"", "\""},
+	DecorationQuotedLiteral: {"‘", "’"},
+	DecorationBraces:        {"{", "}"},
+}
+
+func (d DecoratedSpan) Render(width int, opts Options) string {
+	res := ""
+	for _, span := range d.Contents {
+		res += span.Render(width, opts)
+	}
+	res = strings.Trim(res, " ")
+	res = decorationStyles[d.Typ][0] + res + decorationStyles[d.Typ][1]
+	if !d.NoSpace {
+		res += " "
+	}
+	return res
+}
+
+// Markers are folded into a DecoratedSpan while parsing, so never rendered.
+func (decorationMarker) Render(_ int, _ Options) string {
+	return ""
+}
+
+func (l LiteralLine) Render(width int, opts Options) string {
+	res := ""
+	for _, span := range l.Contents {
+		res += span.Render(width, opts)
+	}
+	res = textStyles[TagLiteral].Render(expandTabs(strings.TrimSuffix(res, " "), opts.TabWidth))
+	return "\n" + noBreak(strings.Repeat(" ", opts.TabWidth)+res) + "\n"
+}
+
+func (c CenteredLine) Render(width int, opts Options) string {
+	res := ""
+	for _, span := range c.Contents {
+		res += span.Render(width, opts)
+	}
+	res = strings.TrimSpace(res)
+	// keep the margin through wrapping, but let long lines wrap
+	margin := max(0, (width-lipgloss.Width(res))/2)
+	return noBreak(strings.Repeat(" ", margin)) + res + "\n"
+}
+
+func (u UnfilledLine) Render(width int, opts Options) string {
+	res := ""
+	for _, span := range u.Contents {
+		res += span.Render(width, opts)
+	}
+	res = expandTabs(strings.TrimRight(res, " "), opts.TabWidth)
+	if u.Literal {
+		res = textStyles[TagLiteral].Render(res)
+	}
+	return noBreak(strings.Repeat(" ", u.Indent*opts.TabWidth)+res) + "\n"
+}
+
+var (
+	commandName    = regexp.MustCompile(`^[a-z_./][a-z0-9_.+/-]*$`)
+	shellOperators = map[string]bool{"|": true, "||": true, "&&": true, ";": true, "&": true}
+)
+
+// Style a literal line that looks like a shell command: command names, flags,
+// paths, and other arguments. Lines that already have markup, or that don't
+// start with something shaped like a command, are left alone.
+func highlightCommand(contents []Span) []Span {
+	var words []string
+	for _, span := range contents {
+		text, ok := span.(TextSpan)
+		if !ok || text.Typ != TagPlain || text.NoSpace {
+			return contents
+		}
+		words = append(words, text.Text)
+	}
+	if len(words) > 0 && (words[0] == "$" || words[0] == "#") {
+		words = words[1:]
+	}
+	if len(words) == 0 || !commandName.MatchString(words[0]) || strings.HasSuffix(words[len(words)-1], ".") {
+		return contents
+	}
+
+	res := contents[:len(contents)-len(words)]
+	res = res[:len(res):len(res)]
+	command := true
+	for _, word := range words {
+		switch {
+		case shellOperators[word] || strings.HasPrefix(word, ">") || strings.HasPrefix(word, "<"):
+			res = append(res, TextSpan{TagPlain, word, false})
+			command = shellOperators[word]
+		case command:
+			res = append(res, TextSpan{TagNameRef, word, false})
+			command = false
+		case strings.HasPrefix(word, "-") && len(word) > 1:
+			res = append(res, FlagSpan{Flag: word})
+		case strings.Contains(word, "/") || strings.HasPrefix(word, "~"):
+			res = append(res, TextSpan{TagPath, word, false})
+		default:
+			res = append(res, TextSpan{TagArg, word, false})
+		}
+	}
+	return res
+}
+
+// Stand-ins for spaces and hyphens that wordwrap won't break lines at, so
+// literal text stays on one line. They're swapped back by Wrap.
+// These are private use characters: wordwrap treats a no-break space as a
+// space like any other.
+const (
+	noBreakSpace  = "\ue000"
+	noBreakHyphen = "\ue001"
+)
+
+func noBreak(s string) string {
+	return strings.NewReplacer(" ", noBreakSpace, "-", noBreakHyphen).Replace(s)
+}
+
+// Wrap rendered contents to width, keeping literal lines whole.
+func Wrap(s string, width int) string {
+	wrapped := ansi.Wordwrap(s, width, "")
+	return closeLinks(strings.NewReplacer(noBreakSpace, " ", noBreakHyphen, "-").Replace(wrapped))
+}
+
+var hyperlinkEscape = regexp.MustCompile("\x1b]8;[^;\x07\x1b]*;([^\x07\x1b]*)(?:\x07|\x1b\\\\)")
+
+// End links that wrapped at the end of each line and start them again on the
+// next, so a line shown on its own, like at the top of the viewer, doesn't
+// leave a link open or lose the start of one.
+func closeLinks(s string) string {
+	if !strings.Contains(s, "\x1b]8;") {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	open := ""
+	for i, line := range lines {
+		if open != "" {
+			line = ansi.SetHyperlink(open) + line
+		}
+		for _, link := range hyperlinkEscape.FindAllStringSubmatch(line, -1) {
+			open = link[1]
+		}
+		if open != "" {
+			line += ansi.ResetHyperlink()
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+var flagStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+
+func (f FlagSpan) Render(_ int, _ Options) string {
+	flag := f.Flag
+
+	dash := ""
+	if f.Dash {
+		dash = "-"
+	}
+	res := flagStyle.Render(dash + flag)
+	if !f.NoSpace {
+		res += " "
+	}
+	return res
+}
+
+// References are underlined like links, and colored unless NO_COLOR is set.
+var manRefStyle = func() lipgloss.Style {
+	style := lipgloss.NewStyle().Underline(true)
+	if os.Getenv("NO_COLOR") == "" {
+		style = style.Foreground(lipgloss.Color("12"))
+	}
+	return style
+}()
+
+func (m ManRef) Render(_ int, _ Options) string {
+	res := m.Name
+	if m.Section != "" {
+		res += "(" + m.Section + ")"
+	}
+	res = manRefStyle.Render(res)
+	if !m.NoSpace {
+		res += " "
+	}
+	return res
+}
+
+// Links show their text followed by the address, like groff does. With
+// Options.Hyperlinks, the text alone is shown as an OSC 8 link to the address.
+func (l LinkSpan) Render(width int, opts Options) string {
+	address := strings.TrimPrefix(l.URL, "mailto:")
+	text := ""
+	for _, span := range l.Contents {
+		text += span.Render(width, opts)
+	}
+	text = strings.TrimSpace(text)
+
+	var res string
+	switch {
+	case opts.Hyperlinks && text != "":
+		res = ansi.SetHyperlink(l.URL) + manRefStyle.Render(text) + ansi.ResetHyperlink()
+	case opts.Hyperlinks:
+		res = ansi.SetHyperlink(l.URL) + manRefStyle.Render(noBreak(address)) + ansi.ResetHyperlink()
+	case text != "" && text != address:
+		res = text + " ⟨" + manRefStyle.Render(noBreak(address)) + "⟩"
+	default:
+		res = manRefStyle.Render(noBreak(address))
+	}
+	if !l.NoSpace {
+		res += " "
+	}
+	return res
+}
+
+var standardStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("12"))
+
+func (std StandardRef) Render(_ int, _ Options) string {
+	res := ""
+	switch std.Standard {
+	case "-ansiC":
+		res = `ANSI X3.159-1989 (“ANSI C89”)`
+	case "-ansiC-89":
+		res = `ANSI X3.159-1989 (“ANSI C89”)`
+	case "-isoC":
+		res = `ISO/IEC 9899:1990 (“ISO C90”)`
+	case "-isoC-90":
+		res = `ISO/IEC 9899:1990 (“ISO C90”)`
+	case "-isoC-amd1":
+		res = `ISO/IEC 9899/AMD1:1995 (“ISO C90, Amendment 1”)`
+	case "-isoC-tcor1":
+		res = `ISO/IEC 9899/TCOR1:1994 (“ISO C90, Technical Corrigendum 1”)`
+	case "-isoC-tcor2":
+		res = `ISO/IEC 9899/TCOR2:1995 (“ISO C90, Technical Corrigendum 2”)`
+	case "-isoC-99":
+		res = `ISO/IEC 9899:1999 (“ISO C99”)`
+	case "-isoC-2011":
+		res = `ISO/IEC 9899:2011 (“ISO C11”)`
+	case "-p1003.1-88":
+		res = `IEEE Std 1003.1-1988 (“POSIX.1”)`
+	case "-p1003.1":
+		res = `IEEE Std 1003.1 (“POSIX.1”)`
+	case "-p1003.1-90":
+		res = `IEEE Std 1003.1-1990 (“POSIX.1”)`
+	case "-iso9945-1-90":
+		res = `ISO/IEC 9945-1:1990 (“POSIX.1”)`
+	case "-p1003.1b-93":
+		res = `IEEE Std 1003.1b-1993 (“POSIX.1b”)`
+	case "-p1003.1b":
+		res = `IEEE Std 1003.1b (“POSIX.1b”)`
+	case "-p1003.1c-95":
+		res = `IEEE Std 1003.1c-1995 (“POSIX.1c”)`
+	case "-p1003.1i-95":
+		res = `IEEE Std 1003.1i-1995 (“POSIX.1i”)`
+	case "-p1003.1-96":
+		res = `ISO/IEC 9945-1:1996 (“POSIX.1”)`
+	case "-iso9945-1-96":
+		res = `ISO/IEC 9945-1:1996 (“POSIX.1”)`
+	case "-p1003.2":
+		res = `IEEE Std 1003.2 (“POSIX.2”)`
+	case "-p1003.2-92":
+		res = `IEEE Std 1003.2-1992 (“POSIX.2”)`
+	case "-iso9945-2-93":
+		res = `ISO/IEC 9945-2:1993 (“POSIX.2”)`
+	case "-p1003.2a-92":
+		res = `IEEE Std 1003.2a-1992 (“POSIX.2”)`
+	case "-xpg4":
+		res = `X/Open Portability Guide Issue 4 (“XPG4”)`
+	case "-susv1":
+		res = `Version 1 of the Single UNIX Specification (“SUSv1”)`
+	case "-xpg4.2":
+		res = `X/Open Portability Guide Issue 4, Version 2 (“XPG4.2”)`
+	case "-xsh4.2":
+		res = `X/Open System Interfaces and Headers Issue 4, Version 2 (“XSH4.2”)`
+	case "-xcurses4.2":
+		res = `X/Open Curses Issue 4, Version 2 (“XCURSES4.2”)`
+	case "-p1003.1g-2000":
+		res = `IEEE Std 1003.1g-2000 (“POSIX.1g”)`
+	case "-svid4":
+		res = `System V Interface Definition, Fourth Edition (“SVID4”),`
+	case "-susv2":
+		res = `Version 2 of the Single UNIX Specification (“SUSv2”)`
+	case "-xbd5":
+		res = `X/Open Base Definitions Issue 5 (“XBD5”)`
+	case "-xsh5":
+		res = `X/Open System Interfaces and Headers Issue 5 (“XSH5”)`
+	case "-xcu5":
+		res = `X/Open Commands and Utilities Issue 5 (“XCU5”)`
+	case "-xns5":
+		res = `X/Open Networking Services Issue 5 (“XNS5”)`
+	case "-xns5.2":
+		res = `X/Open Networking Services Issue 5.2 (“XNS5.2”)`
+	case "-p1003.1-2001":
+		res = `IEEE Std 1003.1-2001 (“POSIX.1”)`
+	case "-susv3":
+		res = `Version 3 of the Single UNIX Specification (“SUSv3”)`
+	case "-p1003.1-2004":
+		res = `IEEE Std 1003.1-2004 (“POSIX.1”)`
+	case "-p1003.1-2008":
+		res = `IEEE Std 1003.1-2008 (“POSIX.1”)`
+	case "-susv4":
+		res = `Version 4 of the Single UNIX Specification (“SUSv4”)`
+	case "-ieee754":
+		res = `IEEE Std 754-1985`
+	case "-iso8601":
+		res = `ISO 8601`
+	case "-iso8802-3":
+		res = `ISO 8802-3: 1989`
+	case "-ieee1275-94":
+		res = `IEEE Std 1275-1994 (“Open Firmware”)`
+	default:
+		res = std.Standard
+	}
+	res = standardStyle.Render(res)
+	if !std.NoSpace {
+		res += " "
+	}
+	return res
+}
+
+func (lib LibraryRef) Render(_ int, _ Options) string {
+	name := ""
+	switch lib.Library {
+	case "libarchive":
+		name = "Streaming Archive Library"
+	case "libbsd":
+		name = "BSD Compatibility Library"
+	case "libbsm":
+		name = "Basic Security Module Library"
+	case "libc":
+		name = "Standard C Library"
+	case "libcrypt":
+		name = "Crypt Library"
+	case "libcrypto":
+		name = "OpenSSL Cryptographic Library"
+	case "libcurses":
+		name = "Curses Library"
+	case "libdl":
+		name = "Dynamic Linking Library"
+	case "libedit":
+		name = "Command Line Editor Library"
+	case "libelf":
+		name = "ELF Parsing Library"
+	case "libexecinfo":
+		name = "Backtrace Information Library"
+	case "libfetch":
+		name = "File Transfer Library"
+	case "libkvm":
+		name = "Kernel Data Access Library"
+	case "libm":
+		name = "Math Library"
+	case "libmd":
+		name = "Message Digest (MD4, MD5, etc.) Support Library"
+	case "libncurses":
+		name = "NCurses Library"
+	case "libpam":
+		name = "Pluggable Authentication Module Library"
+	case "libpcap":
+		name = "Packet Capture Library"
+	case "libpthread":
+		name = "POSIX Threads Library"
+	case "librt":
+		name = "POSIX Real-time Library"
+	case "libssl":
+		name = "OpenSSL SSL/TLS Library"
+	case "libtermcap":
+		name = "Termcap Access Library"
+	case "libthr":
+		name = "1:1 Threading Library"
+	case "libusb":
+		name = "USB Access Library"
+	case "libutil":
+		name = "System Utilities Library"
+	case "libz":
+		name = "Compression Library"
+	}
+	res := standardStyle.Render(lib.Library)
+	if name != "" {
+		link := "-l" + strings.TrimPrefix(lib.Library, "lib")
+		res = standardStyle.Render(fmt.Sprintf("%s (%s, %s)", name, lib.Library, link))
+	}
+	if !lib.NoSpace {
+		res += " "
+	}
+	return res
+}
+
+// How enumerated list items are numbered.
+type EnumStyle int
+
+const (
+	EnumNumbers EnumStyle = iota // 1. 2. 3.
+	EnumLetters                  // a. b. c.
+	EnumRoman                    // i. ii. iii.
+)
+
+// The markers in front of list items. Enumerated lists nested in other
+// enumerated lists use the next style in Enum, staying on the last one.
+type ListMarkers struct {
+	Bullet string
+	Dash   string
+	Enum   []EnumStyle
+}
+
+var (
+	DefaultMarkers = ListMarkers{"•", "-", []EnumStyle{EnumNumbers, EnumLetters, EnumRoman}}
+	// For terminals that can't show Unicode.
+	ASCIIMarkers = ListMarkers{"*", "-", []EnumStyle{EnumNumbers, EnumLetters, EnumRoman}}
+)
+
+// The marker for the nth item of an enumerated list at the given depth.
+func (m ListMarkers) enumMarker(depth, n int) string {
+	style := EnumNumbers
+	if len(m.Enum) > 0 {
+		style = m.Enum[min(depth, len(m.Enum)-1)]
+	}
+	switch {
+	case style == EnumLetters && n <= 26:
+		return fmt.Sprintf("%2c. ", 'a'+n-1)
+	case style == EnumRoman:
+		return fmt.Sprintf("%2s. ", roman(n))
+	default:
+		return fmt.Sprintf("%2d. ", n)
+	}
+}
+
+func roman(n int) string {
+	numerals := []struct {
+		value  int
+		symbol string
+	}{
+		{1000, "m"}, {900, "cm"}, {500, "d"}, {400, "cd"}, {100, "c"}, {90, "xc"},
+		{50, "l"}, {40, "xl"}, {10, "x"}, {9, "ix"}, {5, "v"}, {4, "iv"}, {1, "i"},
+	}
+	res := ""
+	for _, numeral := range numerals {
+		for n >= numeral.value {
+			res += numeral.symbol
+			n -= numeral.value
+		}
+	}
+	return res
+}
+
+func (l List) Render(width int, opts Options) string {
+	if l.Typ == ColumnList {
+		return l.RenderTable(width, opts)
+	}
+
+	res := ""
+	maxTagWidth := 8
+	switch l.Typ {
+	case BulletList:
+		maxTagWidth = max(2, lipgloss.Width(opts.Markers.Bullet)+1)
+	case DashList:
+		maxTagWidth = max(2, lipgloss.Width(opts.Markers.Dash)+1)
+	case TagList, HangList: // tags are 8 columns wide without -width
+		if l.Width > 0 {
+			maxTagWidth = l.Width + 1
+		}
+	case OhangList, InsetList:
+		maxTagWidth = 0
+	case EnumList:
+		maxTagWidth = 4
+		for i := range l.Items {
+			maxTagWidth = max(maxTagWidth, len(opts.Markers.enumMarker(l.Depth, i+1)))
+		}
+	case ItemList, DiagList:
+		maxTagWidth = 0
+	default:
+		panic(fmt.Sprintf("Don't know how to render %d list", l.Typ))
+	}
+	indent := lipgloss.NewStyle().MarginLeft(l.Indent).Render
+	tagFillWidth := lipgloss.NewStyle().Width(maxTagWidth)
+	contentFillWidth := lipgloss.NewStyle().Width(width - maxTagWidth)
+	contentMargin := lipgloss.NewStyle().MarginLeft(maxTagWidth)
+
+	for i, item := range l.Items {
+		res += "\n"
+		if !l.Compact {
+			res += "\n"
+		}
+
+		tag := ""
+
+		switch l.Typ {
+		case TagList, OhangList, HangList:
+			for _, span := range item.Tag {
+				tag += span.Render(width, opts)
+			}
+			tag = strings.TrimSpace(tag)
+		case BulletList:
+			tag = opts.Markers.Bullet + " "
+		case DashList:
+			tag = opts.Markers.Dash + " "
+		case EnumList:
+			tag = opts.Markers.enumMarker(l.Depth, i+1)
+		case DiagList:
+			// the emphasized message starts the item's paragraph
+			for _, span := range item.Tag {
+				tag += span.Render(width, opts)
+			}
+			tag = textStyles[TagBold].Render(strings.TrimSpace(tag)) + " "
+		case InsetList:
+			for _, span := range item.Tag {
+				tag += span.Render(width, opts)
+			}
+			tag = strings.TrimSpace(tag) + " "
+		case ItemList:
+			// no tag
+		default:
+			panic(fmt.Sprintf("Don't know how to render %d list", l.Typ))
+		}
+
+		contents := ""
+		for _, span := range item.Contents {
+			contents += span.Render(width-maxTagWidth, opts)
+		}
+
+		switch {
+		case l.Typ == DiagList || l.Typ == InsetList: // the body runs on after the tag
+			res += contentFillWidth.Render(tag + contents)
+		case l.Typ == HangList && lipgloss.Width(tag) > maxTagWidth:
+			// the body starts beside a tag too wide to hang it under, and
+			// the rest of it is indented
+			text := tag + " " + contents
+			first, _, _ := strings.Cut(ansi.Wordwrap(text, width, ""), "\n")
+			res += first
+			if rest := strings.TrimLeft(text[len(first):], " "); rest != "" {
+				res += "\n" + contentMargin.Render(contentFillWidth.Render(rest))
+			}
+		case lipgloss.Width(tag) > maxTagWidth:
+			contents = contentFillWidth.Render(contents)
+			res += tag
+			res += "\n"
+			res += contentMargin.Render(contents)
+		default:
+			contents = contentFillWidth.Render(contents)
+			tag = tagFillWidth.Render(tag)
+			res += lipgloss.JoinHorizontal(lipgloss.Top, tag, contents)
+		}
+	}
+	return indent(res)
+}
+
+// Spaces between the columns of a .Bl -column table.
+const tableGap = 2
+
+// Render a .Bl -column list. Each column is as wide as its prototype string,
+// and the last one takes the rest of the width, wrapping long cells.
+func (l List) RenderTable(width int, opts Options) string {
+	var widths []int
+	for i, col := range l.Columns {
+		if i == len(l.Columns)-1 {
+			remaining := width
+			for _, w := range widths {
+				remaining -= w + tableGap
+			}
+			widths = append(widths, max(1, remaining))
+		} else {
+			widths = append(widths, lipgloss.Width(col))
+		}
+	}
+
+	var rows []string
+	for _, item := range l.Items {
+		var cells []string
+		cell := ""
+		for _, span := range item.Tag {
+			if len(cells) >= len(widths) { // too many cells in this row, parsing error?
+				break
+			}
+			if ts, ok := span.(TextSpan); ok && ts.Typ == TagTableCellSeparator {
+				cells = append(cells, cell)
+				cell = ""
+				continue
+			}
+			cell += span.Render(widths[len(cells)], opts)
+		}
+		if cell != "" && len(cells) < len(widths) {
+			cells = append(cells, cell)
+		}
+
+		for i, cell := range cells {
+			style := lipgloss.NewStyle().Width(widths[i])
+			if i < len(cells)-1 {
+				style = style.Width(widths[i] + tableGap).PaddingRight(tableGap)
+			}
+			cells[i] = style.Render(strings.TrimRight(cell, " "))
+		}
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, cells...))
+	}
+
+	indent := lipgloss.NewStyle().MarginLeft(l.Indent).Render
+	res := "\n"
+	if !l.Compact {
+		res += "\n"
+	}
+	return res + indent(strings.Join(rows, "\n"))
+}
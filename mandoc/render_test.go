@@ -0,0 +1,425 @@
+package mandoc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestExpandTabs(t *testing.T) {
+	tests := []struct {
+		input    string
+		width    int
+		expected string
+	}{
+		{"no tabs", 8, "no tabs"},
+		{"\tx", 8, "        x"},
+		{"ab\tx", 8, "ab      x"},
+		{"abcdefgh\tx", 8, "abcdefgh        x"},
+		{"a\tb\nc\td", 4, "a   b\nc   d"},
+		{"\x1b[1mbold\x1b[0m\tx", 8, "\x1b[1mbold\x1b[0m    x"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if res := expandTabs(test.input, test.width); res != test.expected {
+				t.Errorf("expandTabs(%q, %d) = %q, wanted %q", test.input, test.width, res, test.expected)
+			}
+		})
+	}
+}
+
+func TestDecorationSpacing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{".Op Fl a\n.Op Fl b", "[-a] [-b] "},
+		{".Op Fl f Ar file", "[-f file] "},
+		{".Op Fl v Oo Fl z Oc", "[-v [-z]] "},
+		{".Oo Fl x Oc Ns Ar y", "[-x]y "},
+		{".Oo\n.Fl a\n.Oo\n.Ar b\n.Oc\n.Oc", "[-a [b]] "},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			p := parser{}
+			page := p.parseMdoc(".Sh SYNOPSIS\n" + test.input)
+			res := ""
+			for _, span := range page.Sections[0].Contents {
+				res += span.Render(80, DefaultOptions())
+			}
+			if res != test.expected {
+				t.Errorf("rendered %q, wanted %q", res, test.expected)
+			}
+		})
+	}
+}
+
+func TestFooter(t *testing.T) {
+	tests := []struct {
+		page     Page
+		width    int
+		expected string
+	}{
+		{Page{Date: "July 4, 2020"}, 30, "July 4, 2020"},
+		{Page{Date: "July 4, 2020", OS: "BSD"}, 30, "BSD      July 4, 2020      BSD"},
+		{Page{Date: "July 4, 2020", OS: "BSD"}, 10, "BSD July 4, 2020"},
+	}
+	for _, test := range tests {
+		if res := test.page.footer(test.width); res != test.expected {
+			t.Errorf("footer(%d) = %q, wanted %q", test.width, res, test.expected)
+		}
+	}
+}
+
+func TestJustify(t *testing.T) {
+	input := "aa bb cc\n  d e\n  ff gg\nlast line\n\nx y"
+	expected := "aa bb cc\n  d      e\n  ff gg\nlast line\n\nx y"
+	if res := justify(input, 10); res != expected {
+		t.Errorf("justify(%q) = %q, wanted %q", input, res, expected)
+	}
+
+	if res := justifyLine("a b c d", 10); res != "a  b  c  d" {
+		t.Errorf("justifyLine = %q", res)
+	}
+	if res := justifyLine("a b c", 8); res != "a  b   c" {
+		t.Errorf("justifyLine = %q", res)
+	}
+	if res := justifyLine("end.  Next one", 16); res != "end.   Next  one" {
+		t.Errorf("justifyLine = %q, wanted the double space kept", res)
+	}
+}
+
+func TestJustifiedBlock(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".SH DESCRIPTION\nragged text wraps here\n.ad b\nbut this text is justified to fill each line\n.ad l\nand this is ragged again")
+	expected := "ragged text wraps\nhere\nbut  this  text   is\njustified  to   fill\neach line and this\nis ragged again"
+	res := strings.ReplaceAll(Wrap(page.Render(20, DefaultOptions()), 20), " \n", "\n")
+	if !strings.Contains(res, expected) {
+		t.Errorf("rendered %q, wanted it to contain %q", res, expected)
+	}
+}
+
+func TestTabsOnlyExpandInUnfilledText(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{".Sh DESCRIPTION\nname\tvalue", "name value"},
+		{".Sh DESCRIPTION\n.Bd -literal\nab\tc\n.Ed", "ab      c"},
+		{".Sh DESCRIPTION\n.Bd -unfilled -offset indent\nab\tc\n.Ed", "        ab      c"},
+		{".Sh DESCRIPTION\n.Dl ab\tc", "        ab      c"},
+	}
+	for _, test := range tests {
+		p := parser{}
+		page := p.parseMdoc(test.input)
+		if res := trimLines(Wrap(page.Sections[0].Render(80, DefaultOptions()), 80)); res != test.expected {
+			t.Errorf("rendered %q as %q, wanted %q", test.input, res, test.expected)
+		}
+	}
+}
+
+func TestLiteralLine(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh EXAMPLES\nList everything:\n.Dl ls -la /some/long/path\nthen stop.")
+	rendered := ""
+	for _, span := range page.Sections[0].Contents {
+		rendered += span.Render(12, DefaultOptions())
+	}
+	res := Wrap(rendered, 12)
+	expected := "List\neverything: \n        ls -la /some/long/path\nthen stop."
+	if res != expected {
+		t.Errorf("rendered %q, wanted %q", res, expected)
+	}
+}
+
+func TestRenderColumns(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh DESCRIPTION\none two three four five six")
+	res := renderColumns(page.Sections[0], 14, DefaultOptions())
+	expected := "one      four\ntwo      five\nthree    six "
+	if res != expected {
+		t.Errorf("renderColumns = %q, wanted %q", res, expected)
+	}
+
+	if !page.Sections[0].isProse() {
+		t.Error("plain text should be prose")
+	}
+	page = p.parseMdoc(".Sh EXAMPLES\n.Dl ls")
+	if page.Sections[0].isProse() {
+		t.Error("literal lines shouldn't be prose")
+	}
+}
+
+func TestTagListWithoutWidth(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh OPTIONS\n.Bl -tag -compact\n.It Fl a\nall\n.It Fl r\nreverse\n.El")
+	if res := trimLines(page.Sections[0].Render(30, DefaultOptions())); res != "-a      all\n-r      reverse" {
+		t.Errorf("rendered %q", res)
+	}
+}
+
+// Remove the padding at the end of each line.
+func trimLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestHighlightCommand(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh EXAMPLES\n.Dl $ ls -la ~/src | grep foo\n.Dl Use this one.\n.Dl Nm Fl l")
+	contents := page.Sections[0].Contents
+
+	expected := []Span{
+		TextSpan{TagPlain, "$", false},
+		TextSpan{TagNameRef, "ls", false},
+		FlagSpan{Flag: "-la"},
+		TextSpan{TagPath, "~/src", false},
+		TextSpan{TagPlain, "|", false},
+		TextSpan{TagNameRef, "grep", false},
+		TextSpan{TagArg, "foo", false},
+	}
+	if res := highlightCommand(contents[0].(LiteralLine).Contents); !reflect.DeepEqual(res, expected) {
+		t.Errorf("highlighted %#v, wanted %#v", res, expected)
+	}
+
+	for _, line := range contents[1:] {
+		original := line.(LiteralLine).Contents
+		if res := highlightCommand(original); !reflect.DeepEqual(res, original) {
+			t.Errorf("highlighted %#v, wanted it unchanged", res)
+		}
+	}
+}
+
+func TestCompactList(t *testing.T) {
+	p := parser{}
+	loose := p.parseMdoc(".Sh LIST\n.Bl -dash\n.It\none\n.It\ntwo\n.El")
+	compact := p.parseMdoc(".Sh LIST\n.Bl -dash -compact\n.It\none\n.It\ntwo\n.El")
+	if res := loose.Sections[0].Render(20, DefaultOptions()); trimLines(res) != "- one\n\n- two" {
+		t.Errorf("rendered %q", trimLines(res))
+	}
+	if res := compact.Sections[0].Render(20, DefaultOptions()); trimLines(res) != "- one\n- two" {
+		t.Errorf("rendered compact list as %q", trimLines(res))
+	}
+
+	loose = p.parseMdoc(".SH LIST\n.IP a\none\n.IP b\ntwo")
+	compact = p.parseMdoc(".SH LIST\n.PD 0\n.IP a\none\n.IP b\ntwo\n.PD")
+	if res := loose.Sections[0].Render(20, DefaultOptions()); trimLines(res) != "a one\n\nb two" {
+		t.Errorf("rendered %q", trimLines(res))
+	}
+	if res := compact.Sections[0].Render(20, DefaultOptions()); trimLines(res) != "a one\nb two" {
+		t.Errorf("rendered compact paragraphs as %q", trimLines(res))
+	}
+	if p.compact {
+		t.Error(".PD didn't reset the paragraph distance")
+	}
+}
+
+func TestListMarkers(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh LIST\n.Bl -enum -compact\n.It\none\n.Bl -enum -compact\n.It\nnested\n.It\nagain\n.El\n.El\n.Bl -bullet -compact\n.It\nbullet\n.El")
+	if res := trimLines(page.Sections[0].Render(30, DefaultOptions())); res != "1. one\n     a. nested\n     b. again\n• bullet" {
+		t.Errorf("rendered %q", res)
+	}
+
+	opts := DefaultOptions()
+	opts.Markers = ASCIIMarkers
+	opts.Markers.Enum = []EnumStyle{EnumRoman}
+	if res := trimLines(page.Sections[0].Render(30, opts)); res != "i. one\n     i. nested\n    ii. again\n* bullet" {
+		t.Errorf("rendered %q with ASCII markers", res)
+	}
+}
+
+func TestDiagList(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh DIAGNOSTICS\n.Bl -diag\n.It \"No such file\"\nThe file couldn't be opened.\n.It Busy\nTry again.\n.El")
+	expected := "No such file The file couldn't be\nopened.\n\nBusy Try again."
+	if res := trimLines(page.Sections[0].Render(34, DefaultOptions())); res != expected {
+		t.Errorf("rendered %q, wanted %q", res, expected)
+	}
+}
+
+func TestHangList(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh FILES\n.Bl -hang -width xxxx\n.It ab\nshort tag with a body that wraps\n.It longtag\nbody runs on\nafter it\n.El")
+	expected := "ab   short tag with\n     a body that\n     wraps\n\nlongtag body runs on\n     after it"
+	if res := trimLines(page.Sections[0].Render(20, DefaultOptions())); res != expected {
+		t.Errorf("rendered %q, wanted %q", res, expected)
+	}
+}
+
+func TestInsetList(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh OPTIONS\n.Bl -inset -compact\n.It Tag\nthe body runs on and wraps\n.It Other\nbody\n.El")
+	expected := "Tag the body runs on\nand wraps\nOther body"
+	if res := trimLines(page.Sections[0].Render(20, DefaultOptions())); res != expected {
+		t.Errorf("rendered %q, wanted %q", res, expected)
+	}
+}
+
+func TestHyperlinks(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".SH BUGS\nReport bugs at\n.UR https://example.com/bugs\nthe tracker\n.UE .\nOr mail\n.MT bugs@example.com\n.ME")
+	page.mergeSpans()
+	opts := DefaultOptions()
+	opts.Hyperlinks = true
+
+	wrapped := Wrap(page.Sections[0].Render(20, opts), 20)
+	for _, line := range strings.Split(wrapped, "\n") {
+		if width := ansi.StringWidth(line); width > 20 {
+			t.Errorf("line %q is %d wide", line, width)
+		}
+	}
+	// a link that wraps is ended and started again around the line break
+	expected := "Report bugs at \x1b]8;;https://example.com/bugs\athe\x1b]8;;\a\n" +
+		"\x1b]8;;https://example.com/bugs\atracker\x1b]8;;\a. Or mail\n" +
+		"\x1b]8;;mailto:bugs@example.com\abugs@example.com\x1b]8;;\a"
+	if wrapped != expected {
+		t.Errorf("linked %q, wanted %q", wrapped, expected)
+	}
+}
+
+func TestRenderWithoutDate(t *testing.T) {
+	page := Page{Sections: []Section{{Name: "NAME", Contents: []Span{TextSpan{TagPlain, "ls", false}}}}}
+	res := page.Render(30, DefaultOptions())
+	if !strings.HasSuffix(res, "\nls") {
+		t.Errorf("rendered a footer rule without a date: %q", res)
+	}
+
+	page.Date = "July 4, 2020"
+	if res := page.Render(30, DefaultOptions()); !strings.Contains(res, "─\nJuly 4, 2020") {
+		t.Errorf("rendered without the date: %q", res)
+	}
+}
+
+func TestRenderManChrome(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(`.TH LS 1 2024-01-01 "GNU coreutils 9.4" "User Commands"` + "\n.SH NAME\nls")
+	lines := strings.Split(strings.TrimSpace(trimLines(page.Render(50, DefaultOptions()))), "\n")
+	if header := lines[0]; strings.Join(strings.Fields(header), " ") != "LS(1) User Commands LS(1)" || len(header) != 50 {
+		t.Errorf("header = %q", header)
+	}
+	if footer := lines[len(lines)-1]; strings.Join(strings.Fields(footer), " ") != "GNU coreutils 9.4 2024-01-01 LS(1)" || len(footer) != 50 {
+		t.Errorf("footer = %q", footer)
+	}
+}
+
+func TestIncludeGroup(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh SYNOPSIS\n.In stdio.h\n.In stdlib.h\n.Ft int\n.Fn puts \"const char *s\"")
+	res := trimLines(page.Sections[0].Render(80, DefaultOptions()))
+	if expected := "#include <stdio.h>\n#include <stdlib.h>\n\nint"; !strings.HasPrefix(res, expected) {
+		t.Errorf("rendered %q, wanted it to start with %q", res, expected)
+	}
+}
+
+func TestStandardSpacing(t *testing.T) {
+	tests := map[string]string{
+		"St -ansiC":             "ANSI X3.159-1989 (“ANSI C89”)",
+		"St -p1003.1 .":         "IEEE Std 1003.1 (“POSIX.1”).",
+		"St -isoC Ar x":         "ISO/IEC 9899:1990 (“ISO C90”) x",
+		"St -p1003.1-2008 Ns ,": "IEEE Std 1003.1-2008 (“POSIX.1”),",
+	}
+	for line, expected := range tests {
+		p := parser{}
+		if res := (Section{Contents: p.parseLine(line)}).Render(80, DefaultOptions()); res != expected {
+			t.Errorf("%s rendered as %q, wanted %q", line, res, expected)
+		}
+	}
+}
+
+func TestCenteredDisplay(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh DESCRIPTION\nbefore\n.Bd -centered\nA Title\n.Sy bold\n.Ed\nafter")
+	page.mergeSpans()
+	expected := "before\n\n        A Title\n          bold\n\nafter"
+	if res := trimLines(Wrap(page.Sections[0].Render(24, DefaultOptions()), 24)); res != expected {
+		t.Errorf("rendered %q, wanted %q", res, expected)
+	}
+}
+
+func TestUnfilledDisplay(t *testing.T) {
+	for _, display := range []string{"-literal", "-unfilled"} {
+		p := parser{}
+		doc := ".Sh DESCRIPTION\nbefore\n.Bd " + display + " -offset indent\nif (x)\n        y = 1;   /* one */\n\n.Sy done\n.Ed\nafter"
+		page := p.parseMdoc(doc)
+		page.mergeSpans()
+		expected := "before\n\n        if (x)\n                y = 1;   /* one */\n\n        done\n\nafter"
+		if res := trimLines(Wrap(page.Sections[0].Render(24, DefaultOptions()), 24)); res != expected {
+			t.Errorf("%s rendered %q, wanted %q", display, res, expected)
+		}
+	}
+}
+
+func TestLineLength(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".SH DESCRIPTION\n.ll 20\nthe quick brown fox jumps over the lazy dog")
+	expected := "the quick brown fox\njumps over the lazy\ndog"
+	if res := trimLines(page.Sections[0].Render(80, DefaultOptions())); res != expected {
+		t.Errorf("rendered %q, wanted %q", res, expected)
+	}
+	if res := Wrap(page.Sections[0].Render(12, DefaultOptions()), 12); strings.Contains(res, "quick brown") {
+		t.Errorf("wrapped wider than the window: %q", res)
+	}
+}
+
+func TestLineLengthPair(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(`.SH DESCRIPTION
+before the change the text is as wide as the window
+.ll 20
+inside the pair the text is no wider than twenty columns
+.ll
+and after it the text is as wide as the window again`)
+	expected := "before the change the text is as wide as the window\n" +
+		"inside the pair the\ntext is no wider\nthan twenty columns and after it the text is as wide as the window again"
+	if res := trimLines(Wrap(page.Sections[0].Render(80, DefaultOptions()), 80)); res != expected {
+		t.Errorf("rendered %q, wanted %q", res, expected)
+	}
+}
+
+func TestDescriptionDash(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh NAME\n.Nm ls\n.Nd list directory contents")
+	if res := page.Sections[0].Render(80, DefaultOptions()); res != "ls – list directory contents" {
+		t.Errorf("rendered %q", res)
+	}
+	opts := DefaultOptions()
+	opts.DescriptionDash = "-"
+	if res := page.Sections[0].Render(80, opts); res != "ls - list directory contents" {
+		t.Errorf("rendered %q with an ASCII dash", res)
+	}
+}
+
+func TestSpacingOff(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh SYNOPSIS\n.Nm tar\n.Sm off\n.Fl a Ar b\n.Oo Fl C\n.Ar dir Oc\n.Sm on\n.Ar file")
+	res := trimLines(page.Sections[0].Render(80, DefaultOptions()))
+	if expected := "tar -ab[-Cdir] file"; !strings.Contains(res, expected) {
+		t.Errorf("rendered %q, wanted it to contain %q", res, expected)
+	}
+}
+
+func TestTwoColumnTable(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh DESCRIPTION\n.Bl -column -compact \"Name\" \"Meaning\"\n.It foo Ta a long description of foo\n.It x Ta y\n.El")
+	expected := "foo   a long description\n      of foo\nx     y"
+	if res := trimLines(page.Sections[0].Render(24, DefaultOptions())); res != expected {
+		t.Errorf("rendered %q, wanted %q", res, expected)
+	}
+}
+
+func TestThreeColumnTable(t *testing.T) {
+	p := parser{}
+	page := p.parseMdoc(".Sh DESCRIPTION\n.Bl -column \"Option\" \"Default\" \"Effect\"\n.It Fl v Ta off Ta print each file as it is copied\n.It Fl n Ta on Ta skip existing files\n.El")
+	expected := "-v      off      print each file\n                 as it is copied\n-n      on       skip existing\n                 files"
+	if res := trimLines(page.Sections[0].Render(32, DefaultOptions())); res != expected {
+		t.Errorf("rendered %q, wanted %q", res, expected)
+	}
+}
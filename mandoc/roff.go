@@ -0,0 +1,359 @@
+package mandoc
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Built in read-only registers. We format like nroff but are groff compatible.
+var builtinRegisters = map[string]int{
+	".g": 1, // groff compatibility mode
+	".T": 0,
+}
+
+func (p *parser) register(name string) (int, bool) {
+	if value, ok := p.registers[name]; ok {
+		return value, true
+	}
+	value, ok := builtinRegisters[name]
+	return value, ok
+}
+
+// .nr name value
+func (p *parser) setRegister(args string) {
+	name, rest := nextToken(args)
+	value, _ := nextToken(rest)
+	n, err := strconv.Atoi(value)
+	if name == "" || err != nil {
+		return
+	}
+	if p.registers == nil {
+		p.registers = map[string]int{}
+	}
+	p.registers[name] = n
+}
+
+// The line length man pages get on a terminal, which relative .ll requests
+// change when the page hasn't set one.
+const defaultLineLength = 78
+
+// Columns in each roff scaling unit on a terminal, with ten characters to the
+// inch. Line lengths default to ems.
+var unitColumns = map[byte]float64{
+	'n': 1,
+	'm': 1,
+	'i': 10,
+	'c': 10 / 2.54,
+	'p': 10.0 / 72,
+	'P': 10.0 / 6,
+}
+
+// The columns a length like 4n or .5i takes, with a bare number in
+// defaultUnit. Returns false if it isn't a length.
+func lengthColumns(length string, defaultUnit byte) (int, bool) {
+	scale := unitColumns[defaultUnit]
+	if n := len(length); n > 0 && unitColumns[length[n-1]] != 0 {
+		scale = unitColumns[length[n-1]]
+		length = length[:n-1]
+	}
+	n, err := strconv.ParseFloat(length, 64)
+	if err != nil {
+		return 0, false
+	}
+	return int(n * scale), true
+}
+
+// .ll [[+|-]length]
+func (p *parser) setLineLength(args string) {
+	arg, _ := nextToken(strings.TrimSpace(args))
+	if arg == "" { // back to the previous length
+		p.lineLength, p.lastLength = p.lastLength, p.lineLength
+		return
+	}
+
+	sign := arg[0]
+	if sign == '+' || sign == '-' {
+		arg = arg[1:]
+	}
+	columns, ok := lengthColumns(arg, 'm')
+	if !ok {
+		return
+	}
+
+	current := p.lineLength
+	if current == 0 {
+		current = defaultLineLength
+	}
+	switch sign {
+	case '+':
+		columns = current + columns
+	case '-':
+		columns = current - columns
+	}
+	p.lastLength, p.lineLength = p.lineLength, max(columns, 1)
+}
+
+var registerRef = regexp.MustCompile(`^\\n(?:\((..)|\[([^\]]*)\]|(.))`)
+
+// Evaluate a single term of a numeric expression: a number or a register
+// reference like \nx, \n(xx, or \n[name]. Unknown terms are true.
+func (p *parser) evalTerm(term string) int {
+	if parts := registerRef.FindStringSubmatch(term); parts != nil {
+		value, ok := p.register(parts[1] + parts[2] + parts[3])
+		if !ok {
+			return 0
+		}
+		return value
+	}
+	if n, err := strconv.Atoi(term); err == nil {
+		return n
+	}
+	return 1
+}
+
+var comparison = regexp.MustCompile(`^(.*?)(<=|>=|==|!=|=|<|>)(.*)$`)
+
+// Evaluate a numeric condition like \n(.g or \n(xx>2.
+func (p *parser) evalNumeric(expr string) bool {
+	parts := comparison.FindStringSubmatch(expr)
+	if parts == nil {
+		return p.evalTerm(expr) > 0
+	}
+
+	left, right := p.evalTerm(parts[1]), p.evalTerm(parts[3])
+	switch parts[2] {
+	case "<":
+		return left < right
+	case ">":
+		return left > right
+	case "<=":
+		return left <= right
+	case ">=":
+		return left >= right
+	case "!=":
+		return left != right
+	default:
+		return left == right
+	}
+}
+
+// Evaluate the condition at the start of a .if or .ie request, returning the
+// result and the body that follows it. We format like nroff, so n is true and
+// t is false. Conditions we don't understand are true, so the body is kept.
+func (p *parser) evalCondition(args string) (bool, string) {
+	args = strings.TrimLeft(args, " ")
+	negate := strings.HasPrefix(args, "!")
+	args = strings.TrimPrefix(args, "!")
+
+	result := true
+	body := ""
+
+	switch {
+	case args == "":
+		// no condition
+	case args[0] == 'n' || args[0] == 'o': // nroff, odd page
+		body = args[1:]
+	case args[0] == 't' || args[0] == 'e': // troff, even page
+		result = false
+		body = args[1:]
+	case args[0] == 'r': // register exists
+		name, rest := nextToken(strings.TrimLeft(args[1:], " "))
+		_, result = p.register(name)
+		body = rest
+	case strings.ContainsRune("dcmFS", rune(args[0])): // string, character, color, font, style exists
+		_, body = nextToken(strings.TrimLeft(args[1:], " "))
+	case args[0] == '\\' || args[0] == '(' || args[0] == '-' || (args[0] >= '0' && args[0] <= '9'):
+		expr, rest, _ := strings.Cut(args, " ")
+		result = p.evalNumeric(strings.Trim(expr, "()"))
+		body = rest
+	default: // string comparison, 'a'b'
+		delim := args[:1]
+		parts := strings.SplitN(args[1:], delim, 3)
+		if len(parts) < 3 {
+			return true, ""
+		}
+		result = parts[0] == parts[1]
+		body = parts[2]
+	}
+
+	if negate {
+		result = !result
+	}
+	return result, strings.TrimLeft(body, " ")
+}
+
+// If line is a .if, .ie, or .el request, whether its branch is taken and the
+// body to evaluate if it is.
+func (p *parser) conditional(line string, ieResults *stack[bool]) (taken bool, body string, ok bool) {
+	switch {
+	case strings.HasPrefix(line, ".if "):
+		taken, body = p.evalCondition(line[4:])
+	case strings.HasPrefix(line, ".ie "):
+		taken, body = p.evalCondition(line[4:])
+		ieResults.Push(taken)
+	case line == ".el" || strings.HasPrefix(line, ".el ") || strings.HasPrefix(line, `.el\`):
+		taken = ieResults.Len() > 0 && !ieResults.Pop()
+		body = strings.TrimLeft(line[3:], " ")
+	default:
+		return false, "", false
+	}
+	return taken, body, true
+}
+
+// Evaluates .if, .ie, and .el requests, passing on the lines in branches that
+// are taken. Bodies can be a single line or a \{ ... \} block.
+type conditionalExpander struct {
+	p         *parser
+	next      lineFunc
+	ieResults stack[bool]
+	skipDepth int // nesting of the \{ block being skipped
+}
+
+func (c *conditionalExpander) add(line string, lineNo int) error {
+	if c.skipDepth > 0 {
+		c.skipDepth += strings.Count(line, `\{`) - strings.Count(line, `\}`)
+		return nil
+	}
+
+	for {
+		taken, body, ok := c.p.conditional(line, &c.ieResults)
+		if !ok {
+			if strings.HasPrefix(line, ".nr ") {
+				c.p.setRegister(line[4:])
+			}
+			if line = strings.ReplaceAll(line, `\}`, ""); line != "." {
+				return c.next(line, lineNo)
+			}
+			return nil
+		}
+		if !taken {
+			c.skipDepth = max(0, strings.Count(body, `\{`)-strings.Count(body, `\}`))
+			return nil
+		}
+
+		// evaluate the body as a line of its own
+		line = strings.TrimLeft(strings.TrimPrefix(body, `\{`), " ")
+		if line == "" {
+			return nil
+		}
+	}
+}
+
+// Split the arguments to a user-defined macro. Arguments are separated by
+// spaces and can be quoted, but unlike nextToken escapes are kept as is.
+func macroArgs(line string) []string {
+	var args []string
+	arg := ""
+	inQuote, inArg := false, false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '"' && inQuote && i+1 < len(line) && line[i+1] == '"': // "" is a literal quote
+			arg += `"`
+			i++
+		case c == '"' && !inArg:
+			inQuote, inArg = true, true
+		case c == '"' && inQuote:
+			inQuote = false
+		case c == ' ' && !inQuote:
+			if inArg {
+				args = append(args, arg)
+			}
+			arg, inArg = "", false
+		default:
+			arg += string(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg)
+	}
+	return args
+}
+
+var macroArg = regexp.MustCompile(`\\\$(\d|\*|@)`)
+
+// Substitute \$1 through \$9, \$*, and \$@ in a macro body line.
+func substituteArgs(line string, args []string) string {
+	return macroArg.ReplaceAllStringFunc(line, func(ref string) string {
+		switch ref[2] {
+		case '*':
+			return strings.Join(args, " ")
+		case '@':
+			quoted := make([]string, len(args))
+			for i, arg := range args {
+				quoted[i] = `"` + arg + `"`
+			}
+			return strings.Join(quoted, " ")
+		}
+		n := int(ref[2] - '0')
+		if n == 0 || n > len(args) {
+			return ""
+		}
+		return args[n-1]
+	})
+}
+
+// Deepest a user-defined macro can be expanded inside another one, so a
+// recursive macro can't hang us.
+const maxMacroDepth = 20
+
+// Records macros defined with .de and .am and replaces each invocation with
+// the macro's body. Expanded lines keep the line number of the invocation.
+type macroExpander struct {
+	p          *parser
+	next       lineFunc
+	defining   string // name of the macro being defined
+	terminator string // line that ends the definition
+}
+
+func (m *macroExpander) expand(line string, lineNo int, depth int) error {
+	if strings.HasPrefix(line, ".") && depth < maxMacroDepth {
+		name, args, _ := strings.Cut(line[1:], " ")
+		if body, ok := m.p.macros[name]; ok {
+			argv := macroArgs(args)
+			for _, bodyLine := range body {
+				if err := m.expand(substituteArgs(bodyLine, argv), lineNo, depth+1); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return m.next(line, lineNo)
+}
+
+func (m *macroExpander) add(line string, lineNo int) error {
+	p := m.p
+	if m.defining != "" {
+		if strings.TrimRight(line, " ") == m.terminator {
+			m.defining = ""
+			return nil
+		}
+		// bodies are read in copy mode, where \\ is an escaped backslash
+		p.macros[m.defining] = append(p.macros[m.defining], strings.ReplaceAll(line, `\\`, `\`))
+		return nil
+	}
+
+	if strings.HasPrefix(line, ".de ") || strings.HasPrefix(line, ".am ") {
+		args := strings.Fields(line[4:])
+		if len(args) == 0 {
+			return nil
+		}
+		if p.macros == nil {
+			p.macros = map[string][]string{}
+		}
+		m.defining = args[0]
+		m.terminator = ".."
+		if len(args) > 1 {
+			m.terminator = "." + args[1]
+		}
+		if strings.HasPrefix(line, ".de") {
+			p.macros[m.defining] = nil
+		}
+		return nil
+	}
+
+	return m.expand(line, lineNo, 0)
+}
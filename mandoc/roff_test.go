@@ -0,0 +1,117 @@
+package mandoc
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExpandConditionals(t *testing.T) {
+	input := strings.Split(`.if n nroff text
+.if t troff text
+.if !t not troff
+.nr xx 3
+.ie \n(xx>2 \{\
+.B big
+more big
+.\}
+.el \{\
+.B small
+.\}
+.if '\*(.T'ps' ps only
+.if \n(.g groff
+.if r xx has xx
+.if r yy has yy
+.ie t troff
+.el nroff`, "\n")
+	var lines []string
+	var lineNos []int
+	p := parser{}
+	conditionals := conditionalExpander{p: &p, next: collectLines(&lines, &lineNos)}
+	joiner := continuationJoiner{next: conditionals.add}
+	for i, line := range input {
+		joiner.add(line, i)
+	}
+	joiner.flush()
+
+	expected := []string{
+		"nroff text",
+		"not troff",
+		".nr xx 3",
+		".B big",
+		"more big",
+		"groff",
+		"has xx",
+		"nroff",
+	}
+	if !slices.Equal(lines, expected) {
+		t.Errorf("expanded to %q, wanted %q", lines, expected)
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	input := strings.Split(`.de Vb
+.ft CW
+.nf
+.ne \\$1
+..
+.de Hi
+Hello, \\$1 and \\$2!
+..
+.am Hi
+.Vb 3
+..
+.de Loop
+.Loop
+..
+.Hi "dear world" you
+.Loop`, "\n")
+	var lines []string
+	var lineNos []int
+	p := parser{}
+	macros := macroExpander{p: &p, next: collectLines(&lines, &lineNos)}
+	for i, line := range input {
+		macros.add(line, i)
+	}
+
+	expected := []string{
+		"Hello, dear world and you!",
+		".ft CW",
+		".nf",
+		".ne 3",
+		".Loop",
+	}
+	if !slices.Equal(lines, expected) {
+		t.Errorf("expanded to %q, wanted %q", lines, expected)
+	}
+	if expectedNos := []int{14, 14, 14, 14, 15}; !slices.Equal(lineNos, expectedNos) {
+		t.Errorf("line numbers %v, wanted %v", lineNos, expectedNos)
+	}
+}
+
+func TestSetLineLength(t *testing.T) {
+	p := parser{}
+	steps := []struct {
+		args     string
+		expected int
+	}{
+		{"60", 60},
+		{"+5", 65},
+		{"-10n", 55},
+		{"4i", 40},
+		{"", 55}, // back to the last length
+		{"", 40},
+		{"junk", 40},
+	}
+	for _, step := range steps {
+		p.setLineLength(step.args)
+		if p.lineLength != step.expected {
+			t.Errorf(".ll %s set %d columns, wanted %d", step.args, p.lineLength, step.expected)
+		}
+	}
+
+	p = parser{}
+	if p.setLineLength("-8"); p.lineLength != defaultLineLength-8 {
+		t.Errorf(".ll -8 set %d columns, wanted %d", p.lineLength, defaultLineLength-8)
+	}
+}
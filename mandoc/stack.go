@@ -1,4 +1,4 @@
-package main
+package mandoc
 
 type stack[T any] struct {
 	items []T
@@ -3,8 +3,14 @@ package main
 import (
 	"fmt"
 	"io"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/benwaffle/doc/mandoc"
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	listview "github.com/charmbracelet/bubbles/list"
@@ -12,7 +18,8 @@ import (
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/muesli/reflow/wordwrap"
+	"github.com/mattn/go-runewidth"
+	"github.com/muesli/reflow/truncate"
 )
 
 type panel int
@@ -21,6 +28,7 @@ const (
 	nav panel = iota
 	contents
 	search
+	jump // typing a section to jump to
 )
 
 type searchResult struct {
@@ -30,14 +38,40 @@ type searchResult struct {
 type searchState struct {
 	results []searchResult
 	current int // index of currently highlighted result
+	origin  int // viewport offset when the search began
+}
+
+// Where search results can be
+type searchScope struct {
+	wholeWord   bool
+	sectionOnly bool
+	start, end  int // lines of the section searched when sectionOnly is set
+}
+
+// A mark command waiting for the letter to name the mark.
+type markCommand int
+
+const (
+	noMark markCommand = iota
+	setMark
+	jumpToMark
+)
+
+// Position of the mouse over a man page reference
+type hoverState struct {
+	active   bool
+	row, col int
 }
 
 type model struct {
-	page         manPage
+	page         mandoc.Page
 	lines        []string
+	words        int
+	sectionLines []int // line of each table of contents entry in lines
 	viewport     viewport.Model
 	navigation   listview.Model
 	searchbox    textinput.Model
+	jumpbox      textinput.Model
 	help         help.Model
 	keys         keyMap
 	searchKeys   searchKeyMap
@@ -45,29 +79,55 @@ type model struct {
 	windowHeight int
 	focus        panel
 	search       searchState
-	debug        string
+	scope        searchScope
+	history      []string // previous searches, oldest first
+	historyIndex int      // position in history while recalling searches
+	historyPath  string   // where history is saved, or "" to not save it
+	showSidebar  bool
+	sidebarWidth int
+	autoHidden   bool   // sidebar hidden because the window is narrow
+	message      string // shown in the footer until the next key press
+	hover        hoverState
+	marks        map[rune]int // line offset of each mark
+	pendingMark  markCommand
+	resultNumber string // digits typed to jump to a search result
+	xOffset      int    // columns scrolled right, when the window is narrower than minContentWidth
+	debug        string // shown in the footer when DOC_DEBUG is set
 }
 
 type keyMap struct {
-	PageDown     key.Binding
-	PageUp       key.Binding
-	HalfPageUp   key.Binding
-	HalfPageDown key.Binding
-	Down         key.Binding
-	Up           key.Binding
-	Top          key.Binding
-	Bottom       key.Binding
-	Navigate     key.Binding
-	BeginSearch  key.Binding
-	Next         key.Binding
-	Previous     key.Binding
-	Help         key.Binding
-	Quit         key.Binding
+	PageDown      key.Binding
+	PageUp        key.Binding
+	HalfPageUp    key.Binding
+	HalfPageDown  key.Binding
+	Down          key.Binding
+	Up            key.Binding
+	Top           key.Binding
+	Bottom        key.Binding
+	ScrollLeft    key.Binding
+	ScrollRight   key.Binding
+	Navigate      key.Binding
+	ToggleSidebar key.Binding
+	GrowSidebar   key.Binding
+	ShrinkSidebar key.Binding
+	SetMark       key.Binding
+	JumpToMark    key.Binding
+	JumpToSection key.Binding
+	BeginSearch   key.Binding
+	ClearSearch   key.Binding
+	Next          key.Binding
+	Previous      key.Binding
+	Help          key.Binding
+	Quit          key.Binding
 }
 
 type searchKeyMap struct {
-	SubmitSearch key.Binding
-	Cancel       key.Binding
+	SubmitSearch      key.Binding
+	Cancel            key.Binding
+	ToggleWholeWord   key.Binding
+	ToggleSectionOnly key.Binding
+	PreviousSearch    key.Binding
+	NextSearch        key.Binding
 }
 
 func defaultKeyMap() keyMap {
@@ -104,14 +164,50 @@ func defaultKeyMap() keyMap {
 			key.WithKeys("G"),
 			key.WithHelp("G", "bottom"),
 		),
+		ScrollLeft: key.NewBinding(
+			key.WithKeys("left"),
+			key.WithHelp("←", "scroll left"),
+		),
+		ScrollRight: key.NewBinding(
+			key.WithKeys("right"),
+			key.WithHelp("→", "scroll right"),
+		),
 		Navigate: key.NewBinding(
 			key.WithKeys("tab"),
 			key.WithHelp("tab", "navigate"),
 		),
+		ToggleSidebar: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "toggle sidebar"),
+		),
+		GrowSidebar: key.NewBinding(
+			key.WithKeys(">"),
+			key.WithHelp(">", "grow sidebar"),
+		),
+		ShrinkSidebar: key.NewBinding(
+			key.WithKeys("<"),
+			key.WithHelp("<", "shrink sidebar"),
+		),
+		SetMark: key.NewBinding(
+			key.WithKeys("m"),
+			key.WithHelp("m", "set mark"),
+		),
+		JumpToMark: key.NewBinding(
+			key.WithKeys("'"),
+			key.WithHelp("'", "jump to mark"),
+		),
+		JumpToSection: key.NewBinding(
+			key.WithKeys(":"),
+			key.WithHelp(":", "go to section"),
+		),
 		BeginSearch: key.NewBinding(
 			key.WithKeys("/"),
 			key.WithHelp("/", "search"),
 		),
+		ClearSearch: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "clear search"),
+		),
 		Next: key.NewBinding(
 			key.WithKeys("n"),
 			key.WithHelp("n", "next"),
@@ -131,6 +227,125 @@ func defaultKeyMap() keyMap {
 	}
 }
 
+// Bindings for people used to emacs. Search keys stay the same.
+func emacsKeyMap() keyMap {
+	k := defaultKeyMap()
+	k.PageDown = key.NewBinding(
+		key.WithKeys("ctrl+v", "pgdown", " "),
+		key.WithHelp("C-v", "page down"),
+	)
+	k.PageUp = key.NewBinding(
+		key.WithKeys("alt+v", "pgup"),
+		key.WithHelp("M-v", "page up"),
+	)
+	k.Down = key.NewBinding(
+		key.WithKeys("ctrl+n", "down"),
+		key.WithHelp("C-n/↓", "down"),
+	)
+	k.Up = key.NewBinding(
+		key.WithKeys("ctrl+p", "up"),
+		key.WithHelp("C-p/↑", "up"),
+	)
+	k.Top = key.NewBinding(
+		key.WithKeys("alt+<", "home"),
+		key.WithHelp("M-<", "top"),
+	)
+	k.Bottom = key.NewBinding(
+		key.WithKeys("alt+>", "end"),
+		key.WithHelp("M->", "bottom"),
+	)
+	k.BeginSearch = key.NewBinding(
+		key.WithKeys("ctrl+s"),
+		key.WithHelp("C-s", "search"),
+	)
+	k.Next = key.NewBinding(
+		key.WithKeys("alt+n"),
+		key.WithHelp("M-n", "next"),
+	)
+	k.Previous = key.NewBinding(
+		key.WithKeys("ctrl+r", "alt+p"),
+		key.WithHelp("C-r", "previous"),
+	)
+	k.Quit = key.NewBinding(
+		key.WithKeys("q", "ctrl+c"),
+		key.WithHelp("q/C-c", "quit"),
+	)
+	return k
+}
+
+// Bindings for people used to less. < and > go to the top and bottom like in
+// less, so the sidebar is resized with [ and ].
+func lessKeyMap() keyMap {
+	k := defaultKeyMap()
+	k.PageDown = key.NewBinding(
+		key.WithKeys("pgdown", " ", "f", "ctrl+f", "ctrl+v", "z"),
+		key.WithHelp("space/f", "page down"),
+	)
+	k.PageUp = key.NewBinding(
+		key.WithKeys("pgup", "b", "ctrl+b", "alt+v", "w"),
+		key.WithHelp("b", "page up"),
+	)
+	k.Down = key.NewBinding(
+		key.WithKeys("down", "j", "e", "ctrl+e", "ctrl+n", "enter"),
+		key.WithHelp("j/e", "down"),
+	)
+	k.Up = key.NewBinding(
+		key.WithKeys("up", "k", "y", "ctrl+y", "ctrl+p"),
+		key.WithHelp("k/y", "up"),
+	)
+	k.Top = key.NewBinding(
+		key.WithKeys("g", "<", "alt+<"),
+		key.WithHelp("g/<", "top"),
+	)
+	k.Bottom = key.NewBinding(
+		key.WithKeys("G", ">", "alt+>"),
+		key.WithHelp("G/>", "bottom"),
+	)
+	k.GrowSidebar = key.NewBinding(
+		key.WithKeys("]"),
+		key.WithHelp("]", "grow sidebar"),
+	)
+	k.ShrinkSidebar = key.NewBinding(
+		key.WithKeys("["),
+		key.WithHelp("[", "shrink sidebar"),
+	)
+	k.Help = key.NewBinding(
+		key.WithKeys("h", "H", "?"),
+		key.WithHelp("h", "toggle help"),
+	)
+	k.Quit = key.NewBinding(
+		key.WithKeys("q", "Q", "ctrl+c"),
+		key.WithHelp("q", "quit"),
+	)
+	return k
+}
+
+// Key binding presets that can be chosen with --keys.
+var keyPresets = map[string]func() keyMap{
+	"default": defaultKeyMap,
+	"emacs":   emacsKeyMap,
+	"less":    lessKeyMap,
+}
+
+// The scrolling keys, for the viewport to handle.
+func (k keyMap) viewportKeyMap() viewport.KeyMap {
+	return viewport.KeyMap{
+		PageDown:     k.PageDown,
+		PageUp:       k.PageUp,
+		HalfPageUp:   k.HalfPageUp,
+		HalfPageDown: k.HalfPageDown,
+		Down:         k.Down,
+		Up:           k.Up,
+	}
+}
+
+// Replace the key bindings.
+func (m *model) setKeys(keys keyMap, searchKeys searchKeyMap) {
+	m.keys = keys
+	m.searchKeys = searchKeys
+	m.viewport.KeyMap = keys.viewportKeyMap()
+}
+
 func (k keyMap) ShortHelp() []key.Binding {
 	return []key.Binding{
 		k.Navigate,
@@ -147,6 +362,7 @@ func (k keyMap) FullHelp() [][]key.Binding {
 		{
 			k.Navigate,
 			k.BeginSearch,
+			k.JumpToSection,
 		}, {
 			k.PageDown,
 			k.PageUp,
@@ -159,9 +375,20 @@ func (k keyMap) FullHelp() [][]key.Binding {
 		}, {
 			k.Top,
 			k.Bottom,
+		}, {
+			k.ScrollLeft,
+			k.ScrollRight,
 		}, {
 			k.Next,
 			k.Previous,
+			k.ClearSearch,
+		}, {
+			k.ToggleSidebar,
+			k.GrowSidebar,
+			k.ShrinkSidebar,
+		}, {
+			k.SetMark,
+			k.JumpToMark,
 		}, {
 			k.Help,
 			k.Quit,
@@ -179,6 +406,21 @@ func defaultSearchKeyMap() searchKeyMap {
 			key.WithKeys("esc"),
 			key.WithHelp("esc", "cancel"),
 		),
+		ToggleWholeWord: key.NewBinding(
+			key.WithKeys("alt+w"),
+			key.WithHelp("M-w", "whole word"),
+		),
+		ToggleSectionOnly: key.NewBinding(
+			key.WithKeys("alt+s"),
+			key.WithHelp("M-s", "this section"),
+		),
+		PreviousSearch: key.NewBinding(
+			key.WithKeys("up"),
+			key.WithHelp("↑/↓", "history"),
+		),
+		NextSearch: key.NewBinding(
+			key.WithKeys("down"),
+		),
 	}
 }
 
@@ -186,6 +428,9 @@ func (sk searchKeyMap) ShortHelp() []key.Binding {
 	return []key.Binding{
 		sk.SubmitSearch,
 		sk.Cancel,
+		sk.ToggleWholeWord,
+		sk.ToggleSectionOnly,
+		sk.PreviousSearch,
 	}
 }
 
@@ -198,6 +443,7 @@ var (
 
 	tocItemStyle         = lipgloss.NewStyle()
 	selectedTocItemStyle = tocItemStyle.Copy().Foreground(lipgloss.Color("#ae00ff"))
+	subsectionItemStyle  = tocItemStyle.Copy().Faint(true).Italic(true)
 
 	focusColor = lipgloss.Color("#64708d")
 
@@ -210,6 +456,9 @@ type navItem string
 
 func (n navItem) FilterValue() string { return string(n) }
 
+// Subsections are indented under their section.
+func (n navItem) isSubsection() bool { return strings.HasPrefix(string(n), " ") }
+
 type navItemDelegate struct{}
 
 func (navItemDelegate) Height() int  { return 1 }
@@ -223,16 +472,19 @@ func (navItemDelegate) Render(w io.Writer, m listview.Model, index int, listItem
 		return
 	}
 
-	str := fmt.Sprintf("%s", i)
+	str := truncate.StringWithTail(string(i), uint(m.Width()), "…")
 
-	if index == m.Index() {
+	switch {
+	case index == m.Index():
 		fmt.Fprint(w, selectedTocItemStyle.Render(str))
-	} else {
+	case i.isSubsection():
+		fmt.Fprint(w, subsectionItemStyle.Render(str))
+	default:
 		fmt.Fprint(w, tocItemStyle.Render(str))
 	}
 }
 
-func NewModel(page manPage) *model {
+func NewModel(page mandoc.Page) *model {
 	m := &model{
 		page:       page,
 		help:       help.New(),
@@ -242,8 +494,11 @@ func NewModel(page manPage) *model {
 		navigation: buildTableOfContents(page),
 		viewport:   viewport.New(0, 0),
 		searchbox:  buildSearchBox(),
-		debug:      "debug text",
+		jumpbox:    buildJumpBox(),
 	}
+	m.showSidebar = true
+	m.sidebarWidth = m.navigation.Width()
+	m.viewport.KeyMap = m.keys.viewportKeyMap()
 
 	return m
 }
@@ -257,15 +512,30 @@ func buildSearchBox() textinput.Model {
 	return t
 }
 
-func buildTableOfContents(page manPage) listview.Model {
+func buildJumpBox() textinput.Model {
+	t := buildSearchBox()
+	t.Prompt = "Go to section: "
+	return t
+}
+
+func buildTableOfContents(page mandoc.Page) listview.Model {
 	var sections []listview.Item
+	// sections and subsections that repeat are listed once, in document order,
+	// so the entry goes to the first of them
+	seen := map[navItem]bool{}
+	add := func(item navItem) {
+		if !seen[item] {
+			seen[item] = true
+			sections = append(sections, item)
+		}
+	}
 	for _, section := range page.Sections {
-		sections = append(sections, navItem(section.Name))
+		add(navItem(section.Name))
 
 		for _, content := range section.Contents {
-			if span, ok := content.(textSpan); ok && span.Typ == tagSubsectionHeader {
+			if span, ok := content.(mandoc.TextSpan); ok && span.Typ == mandoc.TagSubsectionHeader {
 				text := strings.TrimSuffix(span.Text, ":")
-				sections = append(sections, navItem("  "+text))
+				add(navItem("  " + text))
 			}
 		}
 	}
@@ -278,7 +548,9 @@ func buildTableOfContents(page manPage) listview.Model {
 	navigation.SetShowTitle(false)
 	navigation.SetShowStatusBar(false)
 	navigation.SetShowHelp(false)
-	navigation.SetFilteringEnabled(false)
+	// the filter box is shown in the footer instead of above the list
+	navigation.SetShowFilter(false)
+	navigation.FilterInput.Prompt = "Filter sections: "
 
 	return navigation
 }
@@ -296,22 +568,62 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		m.message = ""
 		if m.focus == search {
+			submitted := false
 			switch {
 			case key.Matches(msg, m.searchKeys.Cancel):
 				m.focus = contents
 				m.search.current = 0
+				m.search.results = nil
 				m.searchbox.SetValue("")
 				m.searchbox.Blur()
+				m.renderContents()
+				m.viewport.SetYOffset(m.search.origin)
 			case key.Matches(msg, m.searchKeys.SubmitSearch):
 				m.focus = contents
 				m.searchbox.Blur()
+				m.saveSearch(m.searchbox.Value())
+				submitted = true
+			case key.Matches(msg, m.searchKeys.PreviousSearch):
+				m.recallSearch(m.historyIndex - 1)
+			case key.Matches(msg, m.searchKeys.NextSearch):
+				m.recallSearch(m.historyIndex + 1)
+			case key.Matches(msg, m.searchKeys.ToggleWholeWord):
+				m.scope.wholeWord = !m.scope.wholeWord
+				m.search.current = 0
+			case key.Matches(msg, m.searchKeys.ToggleSectionOnly):
+				m.scope.sectionOnly = !m.scope.sectionOnly
+				m.search.current = 0
 			default:
 				m.searchbox, cmd = m.searchbox.Update(msg)
 				cmds = append(cmds, cmd)
 			}
 			m.updateSearchResults(m.searchbox.Value())
-		} else {
+			if submitted && m.searchbox.Value() != "" && len(m.search.results) == 0 {
+				m.message = "Pattern not found"
+				if bellOnNoMatch {
+					cmds = append(cmds, ringBell)
+				}
+			}
+		} else if m.focus == jump {
+			switch {
+			case key.Matches(msg, m.searchKeys.Cancel):
+				m.focus = contents
+				m.jumpbox.Blur()
+			case key.Matches(msg, m.searchKeys.SubmitSearch):
+				m.focus = contents
+				m.jumpbox.Blur()
+				m.jumpToSection(m.jumpbox.Value())
+			default:
+				m.jumpbox, cmd = m.jumpbox.Update(msg)
+				cmds = append(cmds, cmd)
+			}
+		} else if m.focus == nav && m.navigation.SettingFilter() {
+			cmds = append(cmds, m.updateNavigation(msg))
+		} else if m.pendingMark != noMark {
+			m.finishMark(msg)
+		} else if !m.typeResultNumber(msg) {
 			switch {
 			// case key.Matches(msg, m.keys.PageDown):
 			// 	m.viewport.ViewDown()
@@ -329,32 +641,80 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.viewport.GotoTop()
 			case key.Matches(msg, m.keys.Bottom):
 				m.viewport.GotoBottom()
+			case key.Matches(msg, m.keys.ScrollLeft):
+				m.scrollSideways(-horizontalStep)
+			case key.Matches(msg, m.keys.ScrollRight):
+				m.scrollSideways(horizontalStep)
 			case key.Matches(msg, m.keys.Help):
-				m.help.ShowAll = !m.help.ShowAll
+				m.showFullHelp(!m.help.ShowAll)
 			case key.Matches(msg, m.keys.Navigate):
 				if m.focus == nav {
 					m.focus = contents
 				} else {
 					m.focus = nav
+					if !m.sidebarVisible() {
+						m.showSidebar = true
+						m.autoHidden = false
+						m.layout()
+					}
 				}
+			case key.Matches(msg, m.keys.ToggleSidebar):
+				m.showSidebar = !m.sidebarVisible()
+				m.autoHidden = false
+				if !m.showSidebar && m.focus == nav {
+					m.focus = contents
+				}
+				m.layout()
+			case key.Matches(msg, m.keys.GrowSidebar):
+				// a sidebar already past half the window is left as it is, not shrunk
+				m.sidebarWidth = max(m.sidebarWidth, min(m.sidebarWidth+sidebarStep, m.windowWidth/2))
+				m.layout()
+			case key.Matches(msg, m.keys.ShrinkSidebar):
+				m.sidebarWidth = max(m.sidebarWidth-sidebarStep, minSidebarWidth)
+				m.layout()
+			case key.Matches(msg, m.keys.SetMark):
+				m.pendingMark = setMark
+			case key.Matches(msg, m.keys.JumpToMark):
+				m.pendingMark = jumpToMark
+			case key.Matches(msg, m.keys.JumpToSection):
+				m.focus = jump
+				m.jumpbox.SetValue("")
+				m.jumpbox.Focus()
+				m.showFullHelp(false)
+			case key.Matches(msg, m.keys.BeginSearch) && m.focus == nav:
+				// filter the table of contents with our own keys
+				m.navigation.KeyMap.Filter = m.keys.BeginSearch
+				m.navigation.KeyMap.AcceptWhileFiltering = m.searchKeys.SubmitSearch
+				m.navigation.KeyMap.CancelWhileFiltering = m.searchKeys.Cancel
+				m.showFullHelp(false)
+				cmds = append(cmds, m.updateNavigation(msg))
 			case key.Matches(msg, m.keys.BeginSearch):
 				m.focus = search
 				m.search.current = 0
+				m.search.origin = m.viewport.YOffset
+				m.scope.start, m.scope.end = m.sectionRange(m.viewport.YOffset)
+				m.historyIndex = len(m.history)
 				m.searchbox.Focus()
 				m.searchbox.SetValue("")
-				m.help.ShowAll = false
-			case key.Matches(msg, m.keys.Next):
+				m.showFullHelp(false)
+			case key.Matches(msg, m.keys.ClearSearch) && m.focus == nav && m.navigation.IsFiltered():
+				m.navigation.ResetFilter()
+			case key.Matches(msg, m.keys.ClearSearch):
+				m.search.results = nil
+				m.search.current = 0
+				m.searchbox.SetValue("")
+				m.renderContents()
+			case key.Matches(msg, m.keys.Next) && len(m.search.results) > 0:
 				m.search.current = min(m.search.current+1, len(m.search.results)-1)
 				m.renderContents()
-			case key.Matches(msg, m.keys.Previous):
+			case key.Matches(msg, m.keys.Previous) && len(m.search.results) > 0:
 				m.search.current = max(m.search.current-1, 0)
 				m.renderContents()
 			case key.Matches(msg, m.keys.Quit):
 				return m, tea.Quit
 			default:
 				if m.focus == nav {
-					m.navigation, cmd = m.navigation.Update(msg)
-					cmds = append(cmds, cmd)
+					cmds = append(cmds, m.updateNavigation(msg))
 				} else if m.focus == contents {
 					m.viewport, cmd = m.viewport.Update(msg)
 					cmds = append(cmds, cmd)
@@ -362,42 +722,414 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+	case tea.MouseMsg:
+		cmds = append(cmds, m.handleMouse(msg))
+
 	case tea.WindowSizeMsg:
 		m.windowWidth = msg.Width
 		m.windowHeight = msg.Height
-
-		titleHeight := lipgloss.Height(m.titleView(nav))
-		footerHeight := lipgloss.Height(m.footerView())
-		verticalMargins := titleHeight + footerHeight // +1 for panel margins
-
-		navWidth := lipgloss.Width(m.sidebarView())
-
-		m.renderContents()
-
-		m.viewport.Width = m.windowWidth - navWidth
-		m.viewport.Height = m.windowHeight - verticalMargins
-
-		m.navigation.SetHeight(m.windowHeight - verticalMargins)
+		m.autoHidden = m.windowWidth < narrowWindowWidth
+		if m.autoHidden && m.focus == nav {
+			m.focus = contents
+		}
+		m.layout()
 
 	default:
 		if m.focus == nav {
-			m.navigation, cmd = m.navigation.Update(msg)
-			cmds = append(cmds, cmd)
+			cmds = append(cmds, m.updateNavigation(msg))
 		} else if m.focus == contents {
 			m.viewport, cmd = m.viewport.Update(msg)
 			cmds = append(cmds, cmd)
 		} else if m.focus == search {
 			m.searchbox, cmd = m.searchbox.Update(msg)
 			cmds = append(cmds, cmd)
+		} else if m.focus == jump {
+			m.jumpbox, cmd = m.jumpbox.Update(msg)
+			cmds = append(cmds, cmd)
 		}
 	}
 
 	return m, tea.Batch(cmds...)
 }
 
+// Digits typed in the contents while there are search results pick a result,
+// which is jumped to on enter. Any other key cancels. Returns whether msg was
+// used.
+func (m *model) typeResultNumber(msg tea.KeyMsg) bool {
+	if m.focus != contents || len(m.search.results) == 0 {
+		return false
+	}
+	if msg.Type == tea.KeyRunes && len(msg.Runes) == 1 && unicode.IsDigit(msg.Runes[0]) {
+		m.resultNumber += string(msg.Runes)
+		return true
+	}
+	if m.resultNumber == "" {
+		return false
+	}
+
+	number := m.resultNumber
+	m.resultNumber = ""
+	if msg.Type != tea.KeyEnter {
+		return false
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 || n > len(m.search.results) {
+		m.message = fmt.Sprintf("No result %s, there are %d", number, len(m.search.results))
+		return true
+	}
+	m.search.current = n - 1
+	m.renderContents()
+	return true
+}
+
+// Set or jump to the mark named by the letter typed after m or '.
+func (m *model) finishMark(msg tea.KeyMsg) {
+	command := m.pendingMark
+	m.pendingMark = noMark
+	if msg.Type != tea.KeyRunes || len(msg.Runes) != 1 || !unicode.IsLetter(msg.Runes[0]) {
+		return
+	}
+	name := msg.Runes[0]
+
+	switch command {
+	case setMark:
+		if m.marks == nil {
+			m.marks = map[rune]int{}
+		}
+		m.marks[name] = m.viewport.YOffset
+		m.message = fmt.Sprintf("Set mark %c", name)
+	case jumpToMark:
+		offset, ok := m.marks[name]
+		if !ok {
+			m.message = fmt.Sprintf("Mark %c not set", name)
+			return
+		}
+		m.viewport.SetYOffset(offset)
+	}
+}
+
+// Update the table of contents, scrolling to the selected entry if it changes.
+func (m *model) updateNavigation(msg tea.Msg) tea.Cmd {
+	selected := m.selectedSection()
+	var cmd tea.Cmd
+	m.navigation, cmd = m.navigation.Update(msg)
+	if i := m.selectedSection(); i != selected && i >= 0 {
+		m.gotoSection(i)
+	}
+	return cmd
+}
+
+// The index of the selected table of contents entry among all of them, even
+// while they're filtered, or -1 if nothing matches the filter.
+func (m *model) selectedSection() int {
+	selected := m.navigation.SelectedItem()
+	for i, item := range m.navigation.Items() {
+		if item == selected {
+			return i
+		}
+	}
+	return -1
+}
+
+// The table of contents entry for query: the number of a section, counting
+// from 1 and skipping subsections, or the start of an entry's name.
+func findSection(items []listview.Item, query string) (int, bool) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return 0, false
+	}
+
+	if n, err := strconv.Atoi(query); err == nil {
+		for i, item := range items {
+			if item.(navItem).isSubsection() {
+				continue // subsection
+			}
+			if n--; n == 0 {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	query = strings.ToLower(query)
+	for i, item := range items {
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(string(item.(navItem)))), query) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Scroll to the section named by query, typed after :.
+func (m *model) jumpToSection(query string) {
+	i, ok := findSection(m.navigation.Items(), query)
+	if !ok {
+		if strings.TrimSpace(query) != "" {
+			m.message = fmt.Sprintf("No section %q", query)
+		}
+		return
+	}
+	m.navigation.ResetFilter() // i counts every entry
+	m.navigation.Select(i)
+	m.gotoSection(i)
+}
+
+// Scroll the contents to table of contents entry i.
+func (m *model) gotoSection(i int) {
+	if i >= 0 && i < len(m.sectionLines) {
+		m.viewport.SetYOffset(m.sectionLines[i])
+	}
+}
+
+func (m *model) handleMouse(msg tea.MouseMsg) tea.Cmd {
+	navWidth := lipgloss.Width(m.sidebarView())
+	top := lipgloss.Height(m.titleView(contents)) // both panels start below their title
+	click := msg.Button == tea.MouseButtonLeft && msg.Action == tea.MouseActionPress
+
+	if msg.X < navWidth { // sidebar
+		switch {
+		case msg.Button == tea.MouseButtonWheelUp:
+			m.navigation.CursorUp()
+		case msg.Button == tea.MouseButtonWheelDown:
+			m.navigation.CursorDown()
+		case click && msg.Y >= top:
+			index := m.navigation.Paginator.Page*m.navigation.Paginator.PerPage + msg.Y - top
+			if index < len(m.navigation.VisibleItems()) {
+				m.focus = nav
+				m.navigation.Select(index)
+				m.gotoSection(m.selectedSection())
+			}
+		}
+		return nil
+	}
+
+	if msg.Action == tea.MouseActionMotion {
+		m.updateHover(msg.X-navWidth+m.xOffset, msg.Y-top)
+		return nil
+	}
+
+	if click && msg.Y >= top {
+		row := m.viewport.YOffset + msg.Y - top
+		if row < len(m.lines) {
+			if name, section, ok := manRefAt(m.lines[row], msg.X-navWidth+m.xOffset); ok {
+				m.focus = contents
+				m.followManRef(name, section)
+			}
+		}
+		return nil
+	}
+
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(msg)
+	return cmd
+}
+
+var (
+	ansiEscape     = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]|\x1b]8;[^\x07\x1b]*(?:\x07|\x1b\\\\)") // styles and OSC 8 links
+	renderedManRef = regexp.MustCompile(`([\w.+:-]+)\(([1-9n]\w*)\)`)
+)
+
+func stripAnsi(s string) string {
+	return ansiEscape.ReplaceAllString(s, "")
+}
+
+// The width columns of a styled line starting at column start. Escape codes
+// are all kept, so styles still start and end in the right places.
+func sliceColumns(line string, start, width int) string {
+	var res strings.Builder
+	col := 0
+	for i := 0; i < len(line); {
+		if line[i] == '\x1b' {
+			if loc := ansiEscape.FindStringIndex(line[i:]); loc != nil && loc[0] == 0 {
+				res.WriteString(line[i : i+loc[1]])
+				i += loc[1]
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(line[i:])
+		w := runewidth.RuneWidth(r)
+		if col >= start && col+w <= start+width {
+			res.WriteRune(r)
+		}
+		col += w
+		i += size
+	}
+	return res.String()
+}
+
+// The man page reference, like ls(1), at column col of a rendered line.
+func manRefAt(line string, col int) (name, section string, ok bool) {
+	plain := stripAnsi(line)
+	for _, match := range renderedManRef.FindAllStringSubmatchIndex(plain, -1) {
+		start := runewidth.StringWidth(plain[:match[0]])
+		end := runewidth.StringWidth(plain[:match[1]])
+		if col >= start && col < end {
+			return plain[match[2]:match[3]], plain[match[4]:match[5]], true
+		}
+	}
+	return "", "", false
+}
+
+// Apply style to the man page reference at column col of a rendered line.
+func highlightManRef(line string, col int, style func(...string) string) string {
+	plain := stripAnsi(line)
+	for _, match := range renderedManRef.FindAllStringIndex(plain, -1) {
+		start := runewidth.StringWidth(plain[:match[0]])
+		end := runewidth.StringWidth(plain[:match[1]])
+		if col < start || col >= end {
+			continue
+		}
+
+		// find the same occurrence of the reference in the styled line
+		ref := plain[match[0]:match[1]]
+		nth := strings.Count(plain[:match[0]], ref)
+		index, from := -1, 0
+		for i := 0; i <= nth; i++ {
+			found := strings.Index(line[from:], ref)
+			if found == -1 {
+				return line
+			}
+			index = from + found
+			from = index + len(ref)
+		}
+		return line[:index] + style(ref) + line[index+len(ref):]
+	}
+	return line
+}
+
+// Highlight the man reference at col, row of the viewport, if there is one.
+func (m *model) updateHover(col, row int) {
+	hovering := false
+	if row >= 0 && row+m.viewport.YOffset < len(m.lines) {
+		row += m.viewport.YOffset
+		_, _, hovering = manRefAt(m.lines[row], col)
+	}
+
+	hover := hoverState{}
+	if hovering {
+		hover = hoverState{active: true, row: row, col: col}
+	}
+	if hover != m.hover {
+		m.hover = hover
+		m.viewport.SetContent(m.highlightedContents())
+	}
+}
+
+// Open the page for a reference like ls(1).
+func (m *model) followManRef(name, section string) {
+	paths := findDocsInSection(name, section)
+	if len(paths) == 0 {
+		m.message = fmt.Sprintf("No man page for %s(%s)", name, section)
+		return
+	}
+	page, err := loadManPage(paths[0])
+	if err != nil {
+		m.message = fmt.Sprintf("Can't open %s(%s): %s", name, section, err)
+		return
+	}
+	m.openPage(page)
+}
+
+// Show a different page, resetting the state that belonged to the old one.
+func (m *model) openPage(page mandoc.Page) {
+	m.page = page
+	m.navigation = buildTableOfContents(page)
+	m.sidebarWidth = m.navigation.Width()
+	m.search = searchState{}
+	m.hover = hoverState{}
+	m.marks = nil
+	m.searchbox.SetValue("")
+	m.viewport.GotoTop()
+	m.layout()
+}
+
+// Find the line each table of contents entry is rendered on.
+func (m *model) findSectionLines() {
+	m.sectionLines = nil
+	row, from := 0, 0
+	for _, item := range m.navigation.Items() {
+		name := strings.TrimSpace(string(item.(navItem)))
+		for i := from; i < len(m.lines); i++ {
+			if strings.TrimSuffix(strings.TrimSpace(stripAnsi(m.lines[i])), ":") == name {
+				row, from = i, i+1 // a subsection can share its section's name
+				break
+			}
+		}
+		m.sectionLines = append(m.sectionLines, row)
+	}
+}
+
+const (
+	sidebarStep     = 4 // columns to grow or shrink the sidebar by
+	minSidebarWidth = 8
+)
+
+// Windows narrower than this hide the sidebar to make room for the contents
+var narrowWindowWidth = 80
+
+// Narrower windows scroll the contents sideways instead of wrapping them
+// tighter, set by -min-width. Zero always wraps to the window.
+var minContentWidth = 40
+
+// Width to render pages at instead of fitting them to the window, set by
+// -width. Zero fits the window.
+var pageWidth = 0
+
+// The narrowest -width allowed
+const minPageWidth = 20
+
+// Columns scrolled by the left and right keys
+const horizontalStep = 8
+
+// Ring the terminal bell when a search finds nothing, set by -bell
+var bellOnNoMatch = false
+
+func ringBell() tea.Msg {
+	os.Stdout.WriteString("\a")
+	return nil
+}
+
+func (m model) sidebarVisible() bool {
+	return m.showSidebar && !m.autoHidden
+}
+
+// Show or hide the full help, which changes the height of the footer, keeping
+// the same line at the top of the page.
+func (m *model) showFullHelp(show bool) {
+	if m.help.ShowAll == show {
+		return
+	}
+	m.help.ShowAll = show
+	offset := m.viewport.YOffset
+	m.layout()
+	m.viewport.SetYOffset(offset)
+}
+
+// Size the panels to fit the window and rewrap the contents.
+func (m *model) layout() {
+	m.navigation.SetWidth(m.sidebarWidth)
+
+	titleHeight := lipgloss.Height(m.titleView(nav))
+	footerHeight := lipgloss.Height(m.footerView())
+	verticalMargins := titleHeight + footerHeight // +1 for panel margins
+
+	navWidth := lipgloss.Width(m.sidebarView())
+
+	m.renderContents()
+
+	m.viewport.Width = m.windowWidth - navWidth
+	m.viewport.Height = m.windowHeight - verticalMargins
+
+	m.navigation.SetHeight(m.windowHeight - verticalMargins)
+}
+
 func (m *model) searchForString(query string) []searchResult {
 	var results []searchResult
-	for row := 0; row < len(m.lines); row++ {
+	first, last := 0, len(m.lines)
+	if m.scope.sectionOnly {
+		first, last = m.scope.start, min(m.scope.end, len(m.lines))
+	}
+	for row := first; row < last; row++ {
 		col := 0
 		for {
 			found := strings.Index(m.lines[row][col:], query)
@@ -405,11 +1137,15 @@ func (m *model) searchForString(query string) []searchResult {
 				break
 			}
 
-			results = append(results, searchResult{
-				row: row,
-				col: col + found,
-				len: len(query),
-			})
+			if inEscape(m.lines[row], col+found, len(query)) {
+				// part of a style or a link's address, not text on the page
+			} else if !m.scope.wholeWord || isWholeWord(m.lines[row], col+found, len(query)) {
+				results = append(results, searchResult{
+					row: row,
+					col: col + found,
+					len: len(query),
+				})
+			}
 			col += found + len(query) + 1
 			if col > len(m.lines[row]) {
 				break
@@ -419,45 +1155,203 @@ func (m *model) searchForString(query string) []searchResult {
 	return results
 }
 
+// Whether any of the n bytes at col of a rendered line are in an escape.
+func inEscape(line string, col, n int) bool {
+	for _, loc := range ansiEscape.FindAllStringIndex(line, -1) {
+		if col < loc[1] && col+n > loc[0] {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+var trailingAnsiEscape = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]$")
+
+// Whether the n bytes at col of a rendered line are a whole word. Styling
+// escapes count as word boundaries.
+func isWholeWord(line string, col, n int) bool {
+	before := line[:col]
+	if r, _ := utf8.DecodeLastRuneInString(before); isWordChar(r) && !trailingAnsiEscape.MatchString(before) {
+		return false
+	}
+	after := stripAnsi(line[col+n:])
+	r, _ := utf8.DecodeRuneInString(after)
+	return !isWordChar(r)
+}
+
+// The range of lines in the section of the page containing row.
+func (m *model) sectionRange(row int) (start, end int) {
+	end = len(m.lines)
+	for i, item := range m.navigation.Items() {
+		if i >= len(m.sectionLines) || item.(navItem).isSubsection() {
+			continue // subsection
+		}
+		if m.sectionLines[i] > row {
+			end = m.sectionLines[i]
+			break
+		}
+		start = m.sectionLines[i]
+	}
+	return start, end
+}
+
+// Show search i from the history in the search box. Going past the newest
+// search clears the box.
+func (m *model) recallSearch(i int) {
+	if i < 0 || i > len(m.history) {
+		return
+	}
+	m.historyIndex = i
+	if i == len(m.history) {
+		m.searchbox.SetValue("")
+	} else {
+		m.searchbox.SetValue(m.history[i])
+	}
+	m.searchbox.CursorEnd()
+	m.search.current = 0
+}
+
+// Remember a submitted search.
+func (m *model) saveSearch(query string) {
+	m.history = addToHistory(m.history, query)
+	if m.historyPath == "" {
+		return
+	}
+	if err := saveSearchHistory(m.historyPath, m.history); err != nil {
+		m.message = fmt.Sprintf("Can't save search history: %s", err)
+	}
+}
+
+// Describes the search scope for the footer, e.g. " (whole word)".
+func (s searchScope) String() string {
+	var parts []string
+	if s.wholeWord {
+		parts = append(parts, "whole word")
+	}
+	if s.sectionOnly {
+		parts = append(parts, "this section")
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// Index of the first result at or after row, wrapping around to the first
+// result if they're all before it.
+func firstResultFrom(results []searchResult, row int) int {
+	for i, result := range results {
+		if result.row >= row {
+			return i
+		}
+	}
+	return 0
+}
+
+// Search for query, scrolling to the first match from where the search began.
 func (m *model) updateSearchResults(query string) {
 	if query == "" {
 		return
 	}
 	m.search.results = m.searchForString(query)
+	m.search.current = firstResultFrom(m.search.results, m.search.origin)
+	m.clampCurrentResult()
 	m.renderContents()
 }
 
+// Keep the current result in range after the results change.
+func (m *model) clampCurrentResult() {
+	m.search.current = max(0, min(m.search.current, len(m.search.results)-1))
+}
+
+// Width of the contents panel, and the width the page is rendered at, which is
+// wider when the panel is narrower than minContentWidth.
+func (m model) contentWidths() (view, render int) {
+	view = m.windowWidth - lipgloss.Width(m.sidebarView())
+	if pageWidth > 0 {
+		return view, pageWidth
+	}
+	return view, max(view, minContentWidth)
+}
+
+// Scroll the contents by cols columns, when they're wider than the window.
+func (m *model) scrollSideways(cols int) {
+	view, render := m.contentWidths()
+	m.xOffset = max(0, min(m.xOffset+cols, render-view))
+	m.viewport.SetContent(m.highlightedContents())
+}
+
 func (m *model) renderContents() {
-	navWidth := lipgloss.Width(m.sidebarView())
-	contentWidth := m.windowWidth - navWidth
+	view, contentWidth := m.contentWidths()
+	m.xOffset = max(0, min(m.xOffset, contentWidth-view))
 
-	contents := wordwrap.String(m.page.Render(contentWidth), contentWidth)
+	contents := mandoc.Wrap(m.page.Render(contentWidth, renderOptions), contentWidth)
 	m.lines = strings.Split(contents, "\n")
-	lines := make([]string, len(m.lines))
-	copy(lines, m.lines)
+	m.words = len(strings.Fields(contents))
+	m.findSectionLines()
 
+	m.clampCurrentResult()
 	yOffset := m.viewport.YOffset
-
 	if len(m.search.results) > 0 {
 		result := m.search.results[m.search.current]
-		m.debug = fmt.Sprintf("row[%d] col[%d]", result.row, result.col)
-		line := lines[result.row]
+		yOffset = result.row
+		if result.col < m.xOffset || result.col+result.len > m.xOffset+view {
+			m.xOffset = max(0, min(result.col-view/2, contentWidth-view))
+		}
+	}
 
-		left := line[:result.col]
-		instance := line[result.col : result.col+result.len]
-		right := line[result.col+result.len:]
+	m.viewport.SetContent(m.highlightedContents())
+	m.viewport.SetYOffset(yOffset)
+}
 
-		highlight := lipgloss.NewStyle().Bold(true).Reverse(true).Render
-		line = left + highlight(instance) + right
-		lines[result.row] = line
+// The rendered lines with the current search result and the man reference
+// under the mouse highlighted.
+func (m *model) highlightedContents() string {
+	lines := make([]string, len(m.lines))
+	copy(lines, m.lines)
 
-		contents = strings.Join(lines, "\n")
+	if m.search.current >= 0 && m.search.current < len(m.search.results) {
+		result := m.search.results[m.search.current]
+		if debugEnabled {
+			m.debug = fmt.Sprintf("row[%d] col[%d]", result.row, result.col)
+		}
+		highlightResults(lines, m.search.results, m.search.current, matchStyle.Render, currentMatchStyle.Render)
+	}
 
-		yOffset = result.row
+	if m.hover.active && m.hover.row < len(lines) {
+		lines[m.hover.row] = highlightManRef(lines[m.hover.row], m.hover.col, lipgloss.NewStyle().Reverse(true).Render)
 	}
 
-	m.viewport.SetContent(contents)
-	m.viewport.SetYOffset(yOffset)
+	if view, render := m.contentWidths(); render > view {
+		for i, line := range lines {
+			lines[i] = sliceColumns(line, m.xOffset, view)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+var (
+	matchStyle        = lipgloss.NewStyle().Background(lipgloss.Color("#5c5c00"))
+	currentMatchStyle = lipgloss.NewStyle().Bold(true).Reverse(true)
+)
+
+// Style every search result in lines, using current for the current result.
+func highlightResults(lines []string, results []searchResult, current int, style, currentStyle func(...string) string) {
+	// go backwards so earlier results on a line keep their columns
+	for i := len(results) - 1; i >= 0; i-- {
+		result := results[i]
+		line := lines[result.row]
+		render := style
+		if i == current {
+			render = currentStyle
+		}
+		lines[result.row] = line[:result.col] + render(line[result.col:result.col+result.len]) + line[result.col+result.len:]
+	}
 }
 
 func (m model) View() string {
@@ -471,13 +1365,16 @@ func (m model) titleView(panel panel) string {
 	}
 
 	if panel == nav {
-		return style.Render("Table of Contents")
+		return style.Copy().MaxWidth(m.sidebarWidth).Render("Table of Contents")
 	} else {
-		return style.Render(fmt.Sprintf("%s(%d)", m.page.Name, m.page.Section))
+		return style.Render(fmt.Sprintf("%s(%s)", m.page.Name, m.page.Section))
 	}
 }
 
 func (m model) sidebarView() string {
+	if !m.sidebarVisible() {
+		return ""
+	}
 	style := lipgloss.NewStyle().Margin(0, 2, 0, 1)
 	return style.Render(m.titleView(nav) + "\n" + m.navigation.View())
 }
@@ -505,34 +1402,84 @@ func (m model) mainView() string {
 }
 
 func (m model) scrollPercentageView() string {
-	return scrollPctStyle.Render(fmt.Sprintf("%3.f%%", m.viewport.ScrollPercent()*100))
+	line := min(m.viewport.YOffset+1, len(m.lines))
+	return scrollPctStyle.Render(fmt.Sprintf("line %d/%d %3.f%%", line, len(m.lines), m.viewport.ScrollPercent()*100))
+}
+
+// Words per minute used to estimate reading time
+const readingSpeed = 200
+
+func (m model) lengthView() string {
+	minutes := max(1, (m.words+readingSpeed-1)/readingSpeed)
+	return scrollPctStyle.Render(fmt.Sprintf("%d min read", minutes)) // the line count is shown with the scroll position
+}
+
+func (m model) warningsView() string {
+	if len(m.page.Warnings) == 0 {
+		return ""
+	}
+	return scrollPctStyle.Render(fmt.Sprintf("%d warnings", len(m.page.Warnings)))
+}
+
+// Set DOC_DEBUG to show debugging details in the footer and write the parsed
+// page to ast.json.
+var debugEnabled = os.Getenv("DOC_DEBUG") != ""
+
+func (m model) debugView() string {
+	if !debugEnabled || m.debug == "" {
+		return ""
+	}
+	return scrollPctStyle.Copy().Faint(true).Render(m.debug)
 }
 
 func (m model) footerView() string {
 	margin := lipgloss.NewStyle().Margin(0, 1).Render // whole footer margin
 
-	scrollPct := m.scrollPercentageView()
+	scrollPct := lipgloss.JoinHorizontal(lipgloss.Bottom, m.debugView(), m.warningsView(), m.lengthView(), m.scrollPercentageView())
 	leftWidth := m.windowWidth - lipgloss.Width(scrollPct) - 2
 	helpStyle := lipgloss.NewStyle().Width(leftWidth).Render
 	m.help.Width = leftWidth
 
 	var left string
 
-	if m.focus == search {
+	if m.focus == nav && m.navigation.SettingFilter() {
+		state := fmt.Sprintf("%d of %d sections", len(m.navigation.VisibleItems()), len(m.navigation.Items()))
+		left = lipgloss.JoinVertical(lipgloss.Left, m.navigation.FilterInput.View()+"     "+state,
+			helpStyle(m.help.ShortHelpView([]key.Binding{m.searchKeys.SubmitSearch, m.searchKeys.Cancel})))
+	} else if m.focus == nav && m.navigation.IsFiltered() {
+		status := fmt.Sprintf("Sections matching `%s', press %s to show all", m.navigation.FilterValue(), m.keys.ClearSearch.Help().Key)
+		left = lipgloss.JoinVertical(lipgloss.Left, status, helpStyle(m.help.View(m.keys)))
+	} else if m.focus == jump {
+		left = lipgloss.JoinVertical(lipgloss.Left, m.jumpbox.View(),
+			helpStyle(m.help.ShortHelpView([]key.Binding{m.searchKeys.SubmitSearch, m.searchKeys.Cancel})))
+	} else if m.focus == search {
 		searchState := ""
-		if m.searchbox.Value() != "" {
+		if m.searchbox.Value() != "" && len(m.search.results) == 0 {
+			searchState = "Pattern not found"
+		} else if m.searchbox.Value() != "" {
 			searchState = fmt.Sprintf("Found %d results for `%s'", len(m.search.results), m.searchbox.Value())
 		}
+		searchState += m.scope.String()
 		left = lipgloss.JoinVertical(lipgloss.Left,
 			m.searchbox.View()+"     "+searchState,
 			helpStyle(m.help.View(m.searchKeys)))
 	} else if len(m.search.results) > 0 {
+		status := fmt.Sprintf("Found %d results for `%s'%s", len(m.search.results), m.searchbox.Value(), m.scope)
+		if m.resultNumber != "" {
+			status = fmt.Sprintf("Go to result %s of %d", m.resultNumber, len(m.search.results))
+		} else if m.message != "" {
+			status = m.message
+		}
+		left = lipgloss.JoinVertical(lipgloss.Left, status, helpStyle(m.help.View(m.keys)))
+	} else if m.message != "" {
+		left = lipgloss.JoinVertical(lipgloss.Left, m.message, helpStyle(m.help.View(m.keys)))
+	} else if m.autoHidden && m.showSidebar {
 		left = lipgloss.JoinVertical(lipgloss.Left,
-			fmt.Sprintf("Found %d results for `%s'", len(m.search.results), m.searchbox.Value()),
+			fmt.Sprintf("Sidebar hidden in narrow window, press %s to show it", m.keys.ToggleSidebar.Help().Key),
 			helpStyle(m.help.View(m.keys)))
 	} else {
 		left = helpStyle(m.help.View(m.keys))
 	}
 
-	return margin(lipgloss.JoinHorizontal(lipgloss.Bottom, left, scrollPct)) //+ "\n" + m.debug
+	return margin(lipgloss.JoinHorizontal(lipgloss.Bottom, left, scrollPct))
 }
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/benwaffle/doc/mandoc"
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestManRefAt(t *testing.T) {
+	line := "see \x1b[4mls(1)\x1b[0m and printf(3p) for more"
+	tests := []struct {
+		col     int
+		name    string
+		section string
+		ok      bool
+	}{
+		{0, "", "", false},
+		{4, "ls", "1", true},
+		{8, "ls", "1", true},
+		{9, "", "", false},
+		{14, "printf", "3p", true},
+	}
+
+	for _, test := range tests {
+		name, section, ok := manRefAt(line, test.col)
+		if name != test.name || section != test.section || ok != test.ok {
+			t.Errorf("manRefAt(%q, %d) = %q, %q, %v, wanted %q, %q, %v", line, test.col, name, section, ok, test.name, test.section, test.ok)
+		}
+	}
+}
+
+func TestHighlightManRef(t *testing.T) {
+	brackets := func(s ...string) string { return "[" + s[0] + "]" }
+	line := "ls(1), \x1b[4mls(1)\x1b[0m"
+
+	if res, expected := highlightManRef(line, 8, brackets), "ls(1), \x1b[4m[ls(1)]\x1b[0m"; res != expected {
+		t.Errorf("highlightManRef(%q, 8) = %q, wanted %q", line, res, expected)
+	}
+	if res := highlightManRef(line, 5, brackets); res != line {
+		t.Errorf("highlightManRef(%q, 5) = %q, wanted it unchanged", line, res)
+	}
+}
+
+func TestMarks(t *testing.T) {
+	var m tea.Model = NewModel(mandoc.Page{})
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 20})
+	mm := m.(model)
+	mm.viewport.SetContent(strings.Repeat("line\n", 200))
+	m = mm
+
+	press := func(s string) {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)})
+	}
+
+	press("d")
+	offset := m.(model).viewport.YOffset
+	if offset == 0 {
+		t.Fatal("expected scrolling down")
+	}
+	press("m")
+	press("a")
+	if msg := m.(model).message; msg != "Set mark a" {
+		t.Errorf("message = %q", msg)
+	}
+	press("g")
+	press("'")
+	press("a")
+	if y := m.(model).viewport.YOffset; y != offset {
+		t.Errorf("jumped to %d, wanted %d", y, offset)
+	}
+	press("'")
+	press("b")
+	if msg := m.(model).message; msg != "Mark b not set" {
+		t.Errorf("message = %q", msg)
+	}
+}
+
+func TestIsWholeWord(t *testing.T) {
+	tests := []struct {
+		line     string
+		col, n   int
+		expected bool
+	}{
+		{"the file", 4, 4, true},
+		{"the files", 4, 4, false},
+		{"profile", 3, 4, false},
+		{"\x1b[1mfile\x1b[0m: x", 4, 4, true},
+		{"a_file", 2, 4, false},
+	}
+	for _, test := range tests {
+		if res := isWholeWord(test.line, test.col, test.n); res != test.expected {
+			t.Errorf("isWholeWord(%q, %d, %d) = %v, wanted %v", test.line, test.col, test.n, res, test.expected)
+		}
+	}
+}
+
+func TestInEscape(t *testing.T) {
+	line := "see \x1b]8;;https://example.com\adocs\x1b]8;;\a here"
+	tests := []struct {
+		col, n   int
+		expected bool
+	}{
+		{strings.Index(line, "example"), 7, true},
+		{strings.Index(line, "docs"), 4, false},
+		{strings.Index(line, "here"), 4, false},
+		{0, 3, false},
+	}
+	for _, test := range tests {
+		if res := inEscape(line, test.col, test.n); res != test.expected {
+			t.Errorf("inEscape(%q, %d, %d) = %v, wanted %v", line, test.col, test.n, res, test.expected)
+		}
+	}
+}
+
+func TestSearchScope(t *testing.T) {
+	m := NewModel(mandoc.Page{})
+	m.lines = []string{"NAME", "  file", "OPTIONS", "  file", "  files"}
+	m.navigation = buildTableOfContents(mandoc.Page{Sections: []mandoc.Section{{Name: "NAME"}, {Name: "OPTIONS"}}})
+	m.findSectionLines()
+
+	if start, end := m.sectionRange(3); start != 2 || end != 5 {
+		t.Errorf("sectionRange(3) = %d, %d, wanted 2, 5", start, end)
+	}
+
+	m.scope = searchScope{wholeWord: true}
+	if results := m.searchForString("file"); len(results) != 2 {
+		t.Errorf("whole word search found %v", results)
+	}
+	m.scope = searchScope{sectionOnly: true, start: 2, end: 5}
+	if results := m.searchForString("file"); len(results) != 2 || results[0].row != 3 {
+		t.Errorf("section search found %v", results)
+	}
+}
+
+func TestFirstResultFrom(t *testing.T) {
+	results := []searchResult{{row: 2}, {row: 5}, {row: 5}, {row: 9}}
+	for _, test := range []struct{ row, expected int }{{0, 0}, {3, 1}, {5, 1}, {9, 3}, {10, 0}} {
+		if res := firstResultFrom(results, test.row); res != test.expected {
+			t.Errorf("firstResultFrom(%d) = %d, wanted %d", test.row, res, test.expected)
+		}
+	}
+	if res := firstResultFrom(nil, 3); res != 0 {
+		t.Errorf("firstResultFrom(nil) = %d, wanted 0", res)
+	}
+}
+
+func TestHighlightResults(t *testing.T) {
+	brackets := func(s ...string) string { return "[" + s[0] + "]" }
+	braces := func(s ...string) string { return "{" + s[0] + "}" }
+	lines := []string{"foo bar foo", "bar", "foo"}
+	results := []searchResult{{0, 0, 3}, {0, 8, 3}, {2, 0, 3}}
+
+	highlightResults(lines, results, 1, brackets, braces)
+	expected := []string{"[foo] bar {foo}", "bar", "[foo]"}
+	for i := range lines {
+		if lines[i] != expected[i] {
+			t.Errorf("line %d = %q, wanted %q", i, lines[i], expected[i])
+		}
+	}
+}
+
+func TestFindSection(t *testing.T) {
+	items := buildTableOfContents(mandoc.Page{Sections: []mandoc.Section{
+		{Name: "NAME"},
+		{Name: "DESCRIPTION", Contents: []mandoc.Span{mandoc.TextSpan{Typ: mandoc.TagSubsectionHeader, Text: "Options:"}}},
+		{Name: "SEE ALSO"},
+	}}).Items()
+
+	tests := []struct {
+		query string
+		index int
+		ok    bool
+	}{
+		{"1", 0, true},
+		{"3", 3, true},
+		{"4", 0, false},
+		{"see", 3, true},
+		{"opt", 2, true},
+		{"EXAMPLES", 0, false},
+		{" ", 0, false},
+	}
+	for _, test := range tests {
+		if index, ok := findSection(items, test.query); index != test.index || ok != test.ok {
+			t.Errorf("findSection(%q) = %d, %v, wanted %d, %v", test.query, index, ok, test.index, test.ok)
+		}
+	}
+}
+
+func TestReadingTimeInFooter(t *testing.T) {
+	var m tea.Model = NewModel(parse(t, ".Sh DESCRIPTION\n"+strings.Repeat("word ", 450)))
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 20})
+	if footer := m.(model).footerView(); !strings.Contains(footer, "3 min read") {
+		t.Errorf("footer %q doesn't show 3 minutes for 450 words", footer)
+	}
+
+	wide := len(m.(model).lines)
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 60, Height: 20})
+	narrow := len(m.(model).lines)
+	if narrow <= wide {
+		t.Fatalf("%d lines in a narrow window, wanted more than %d", narrow, wide)
+	}
+	if footer := m.(model).footerView(); !strings.Contains(footer, fmt.Sprint(narrow)) {
+		t.Errorf("footer %q doesn't count the %d lines after resizing", footer, narrow)
+	}
+}
+
+func TestLinePositionInFooter(t *testing.T) {
+	var m tea.Model = NewModel(parse(t, ".Sh NAME\n"+strings.Repeat("line\n.Pp\n", 100)))
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 20})
+	total := len(m.(model).lines)
+	if footer := m.(model).footerView(); !strings.Contains(footer, fmt.Sprintf("line 1/%d", total)) {
+		t.Errorf("footer %q doesn't show the first line of %d", footer, total)
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if footer := m.(model).footerView(); !strings.Contains(footer, fmt.Sprintf("line 3/%d", total)) {
+		t.Errorf("footer %q after scrolling two lines, wanted line 3/%d", footer, total)
+	}
+}
+
+func TestGrowSidebar(t *testing.T) {
+	var m tea.Model = NewModel(parse(t, ".Sh NAME\nx\n.Sh A VERY LONG SECTION NAME INDEED\ny"))
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 20})
+	press := func(s string) {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)})
+	}
+
+	width := m.(model).sidebarWidth
+	press(">")
+	if w := m.(model).sidebarWidth; w != width+sidebarStep {
+		t.Errorf("sidebar %d wide after growing, wanted %d", w, width+sidebarStep)
+	}
+	for i := 0; i < 20; i++ {
+		press(">")
+	}
+	if w := m.(model).sidebarWidth; w != 50 {
+		t.Errorf("sidebar %d wide after growing a lot, wanted half the window", w)
+	}
+
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 60, Height: 20})
+	press(">")
+	if w := m.(model).sidebarWidth; w != 50 {
+		t.Errorf("sidebar %d wide after growing past the cap, wanted it left at 50", w)
+	}
+}
+
+func TestKeyPresets(t *testing.T) {
+	keyMsg := func(s string) tea.KeyMsg {
+		switch s {
+		case "ctrl+v":
+			return tea.KeyMsg{Type: tea.KeyCtrlV}
+		case "ctrl+s":
+			return tea.KeyMsg{Type: tea.KeyCtrlS}
+		case "ctrl+r":
+			return tea.KeyMsg{Type: tea.KeyCtrlR}
+		case "alt+v":
+			return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("v"), Alt: true}
+		}
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+	}
+	tests := []struct {
+		preset   string
+		binding  func(keyMap) key.Binding
+		keys     []string
+		notKeys  []string
+		helpKeys string
+	}{
+		{"default", func(k keyMap) key.Binding { return k.PageDown }, []string{"f", " "}, []string{"ctrl+v"}, "f/pgdn"},
+		{"default", func(k keyMap) key.Binding { return k.BeginSearch }, []string{"/"}, []string{"ctrl+s"}, "/"},
+		{"emacs", func(k keyMap) key.Binding { return k.PageDown }, []string{"ctrl+v", " "}, []string{"f"}, "C-v"},
+		{"emacs", func(k keyMap) key.Binding { return k.PageUp }, []string{"alt+v"}, []string{"b"}, "M-v"},
+		{"emacs", func(k keyMap) key.Binding { return k.BeginSearch }, []string{"ctrl+s"}, []string{"/"}, "C-s"},
+		{"emacs", func(k keyMap) key.Binding { return k.Previous }, []string{"ctrl+r"}, []string{"N"}, "C-r"},
+		{"less", func(k keyMap) key.Binding { return k.PageDown }, []string{"f", " ", "z", "ctrl+v"}, nil, "space/f"},
+		{"less", func(k keyMap) key.Binding { return k.Down }, []string{"j", "e"}, nil, "j/e"},
+		{"less", func(k keyMap) key.Binding { return k.Up }, []string{"k", "y"}, nil, "k/y"},
+		{"less", func(k keyMap) key.Binding { return k.Bottom }, []string{"G", ">"}, nil, "G/>"},
+		{"less", func(k keyMap) key.Binding { return k.GrowSidebar }, []string{"]"}, []string{">"}, "]"},
+	}
+	for _, test := range tests {
+		binding := test.binding(keyPresets[test.preset]())
+		for _, k := range test.keys {
+			if !key.Matches(keyMsg(k), binding) {
+				t.Errorf("%s: %q doesn't trigger %q", test.preset, k, binding.Help().Desc)
+			}
+		}
+		for _, k := range test.notKeys {
+			if key.Matches(keyMsg(k), binding) {
+				t.Errorf("%s: %q triggers %q", test.preset, k, binding.Help().Desc)
+			}
+		}
+		if help := binding.Help().Key; help != test.helpKeys {
+			t.Errorf("%s: %q is shown as %q, wanted %q", test.preset, binding.Help().Desc, help, test.helpKeys)
+		}
+	}
+
+	m := NewModel(mandoc.Page{})
+	m.setKeys(emacsKeyMap(), defaultSearchKeyMap())
+	m.windowWidth, m.windowHeight = 100, 20
+	if footer := m.footerView(); !strings.Contains(footer, "C-s") {
+		t.Errorf("footer %q doesn't show the emacs search key", footer)
+	}
+}
+
+func TestSearchWithoutResults(t *testing.T) {
+	page := mandoc.Page{Sections: []mandoc.Section{{Name: "NAME", Contents: []mandoc.Span{mandoc.TextSpan{Text: "ls"}}}}}
+	var m tea.Model = NewModel(page)
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 20})
+
+	press := func(msg tea.KeyMsg) {
+		m, _ = m.Update(msg)
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/")})
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("zzz")})
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if msg := m.(model).message; msg != "Pattern not found" {
+		t.Errorf("message = %q", msg)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("N")})
+	if current := m.(model).search.current; current != 0 {
+		t.Errorf("current result = %d after n and N with no results", current)
+	}
+}
+
+func TestFilterSections(t *testing.T) {
+	page := parse(t, ".Sh NAME\nls\n.Sh DESCRIPTION\ntext\n.Sh ENVIRONMENT\nvars\n.Sh BUGS\nsome"+strings.Repeat("\n.Pp\nmore", 10))
+	initial := NewModel(page)
+	initial.navigation.FilterInput.Cursor.SetMode(cursor.CursorStatic) // no blinking to wait for
+	var m tea.Model = initial
+
+	// run commands too, since the list filters in the background
+	var press func(msg tea.Msg)
+	var run func(cmd tea.Cmd)
+	run = func(cmd tea.Cmd) {
+		if cmd == nil {
+			return
+		}
+		switch msg := cmd().(type) {
+		case tea.BatchMsg:
+			for _, cmd := range msg {
+				run(cmd)
+			}
+		case nil:
+		default:
+			press(msg)
+		}
+	}
+	press = func(msg tea.Msg) {
+		var cmd tea.Cmd
+		m, cmd = m.Update(msg)
+		run(cmd)
+	}
+	press(tea.WindowSizeMsg{Width: 100, Height: 10})
+	press(tea.KeyMsg{Type: tea.KeyTab})
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/")})
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("bugs")})
+	if m.(model).focus != nav || !m.(model).navigation.SettingFilter() {
+		t.Fatal("/ in the sidebar didn't filter the table of contents")
+	}
+	if footer := m.(model).footerView(); !strings.Contains(footer, "Filter sections: bugs") || !strings.Contains(footer, "1 of 4 sections") {
+		t.Errorf("footer = %q", footer)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	mm := m.(model)
+	if i := mm.selectedSection(); i != 3 || mm.viewport.YOffset != mm.sectionLines[3] {
+		t.Errorf("selected entry %d at line %d, wanted BUGS at line %d", i, mm.viewport.YOffset, mm.sectionLines[3])
+	}
+	if mm.searchbox.Value() != "" || mm.focus != nav {
+		t.Error("filtering the sidebar started a search")
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.(model).navigation.IsFiltered() {
+		t.Error("esc didn't clear the filter")
+	}
+}
+
+func TestShrinkingSearchResults(t *testing.T) {
+	m := NewModel(mandoc.Page{})
+	m.windowWidth, m.windowHeight = 100, 20
+	for i := 0; i < 6; i++ {
+		m.search.results = append(m.search.results, searchResult{row: 0, col: 0, len: 0})
+	}
+	m.search.current = 5
+
+	m.search.results = m.search.results[:2]
+	m.renderContents()
+	if m.search.current != 1 {
+		t.Errorf("current result = %d, wanted it clamped to 1", m.search.current)
+	}
+
+	m.search.results = nil
+	m.search.current = 3
+	m.renderContents()
+	if m.search.current != 0 {
+		t.Errorf("current result = %d with no results", m.search.current)
+	}
+}
+
+func TestJumpToResultNumber(t *testing.T) {
+	page := mandoc.Page{Sections: []mandoc.Section{{Name: "NAME", Contents: []mandoc.Span{mandoc.TextSpan{Text: "a b a c a"}}}}}
+	var m tea.Model = NewModel(page)
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 20})
+
+	press := func(msg tea.KeyMsg) {
+		m, _ = m.Update(msg)
+	}
+	typeKeys := func(s string) {
+		press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)})
+	}
+	typeKeys("/")
+	typeKeys("a")
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if n := len(m.(model).search.results); n != 3 {
+		t.Fatalf("found %d results, wanted 3", n)
+	}
+
+	typeKeys("3")
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if current := m.(model).search.current; current != 2 {
+		t.Errorf("current result = %d, wanted 2", current)
+	}
+
+	typeKeys("9")
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if current := m.(model).search.current; current != 2 {
+		t.Errorf("current result = %d after an out of range number", current)
+	}
+	if msg := m.(model).message; msg != "No result 9, there are 3" {
+		t.Errorf("message = %q", msg)
+	}
+}
+
+func TestToggleHelpKeepsPosition(t *testing.T) {
+	var m tea.Model = NewModel(parse(t, ".Sh NAME\n"+strings.Repeat("line\n.Pp\n", 100)))
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 20})
+	press := func(s string) {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)})
+	}
+
+	press("d")
+	offset := m.(model).viewport.YOffset
+	height := m.(model).viewport.Height
+	press("?")
+	if h := m.(model).viewport.Height; h >= height {
+		t.Errorf("viewport height = %d with full help, wanted less than %d", h, height)
+	}
+	if y := m.(model).viewport.YOffset; y != offset {
+		t.Errorf("offset = %d with full help, wanted %d", y, offset)
+	}
+	press("?")
+	if h := m.(model).viewport.Height; h != height {
+		t.Errorf("viewport height = %d after hiding help, wanted %d", h, height)
+	}
+	if y := m.(model).viewport.YOffset; y != offset {
+		t.Errorf("offset = %d after hiding help, wanted %d", y, offset)
+	}
+}
+
+func TestSubsectionLines(t *testing.T) {
+	page := parse(t, ".Sh DESCRIPTION\nintro\n.Ss Options\nsome options\n.Ss Files\nfiles\n.Sh EXIT STATUS\nzero")
+	m := NewModel(page)
+	m.windowWidth, m.windowHeight = 100, 10
+	m.layout()
+
+	items := m.navigation.Items()
+	if len(items) != 4 || !items[1].(navItem).isSubsection() || items[3].(navItem).isSubsection() {
+		t.Fatalf("table of contents = %v", items)
+	}
+	for i, item := range items {
+		name := strings.TrimSpace(string(item.(navItem)))
+		if line := stripAnsi(m.lines[m.sectionLines[i]]); !strings.Contains(line, name) {
+			t.Errorf("%s is on line %d, %q", name, m.sectionLines[i], line)
+		}
+	}
+
+	m.gotoSection(2)
+	if m.viewport.YOffset != m.sectionLines[2] {
+		t.Errorf("scrolled to %d, wanted %d", m.viewport.YOffset, m.sectionLines[2])
+	}
+}
+
+func TestRepeatedSections(t *testing.T) {
+	page := parse(t, ".Sh NOTES\nfirst\n.Ss More\na\n.Sh BUGS\nsome\n.Sh NOTES\nsecond\n.Ss More\nb")
+	m := NewModel(page)
+	m.windowWidth, m.windowHeight = 100, 10
+	m.layout()
+
+	var names []string
+	for _, item := range m.navigation.Items() {
+		names = append(names, strings.TrimSpace(string(item.(navItem))))
+	}
+	if !slices.Equal(names, []string{"NOTES", "More", "BUGS"}) {
+		t.Fatalf("table of contents = %q", names)
+	}
+	if m.sectionLines[0] != 0 || m.sectionLines[1] >= m.sectionLines[2] {
+		t.Errorf("section lines = %v, wanted the first NOTES and More", m.sectionLines)
+	}
+}
+
+func TestSliceColumns(t *testing.T) {
+	tests := []struct {
+		line         string
+		start, width int
+		expected     string
+	}{
+		{"abcdefgh", 2, 3, "cde"},
+		{"abc", 2, 5, "c"},
+		{"\x1b[1mbold\x1b[0m plain", 2, 4, "\x1b[1mld\x1b[0m p"},
+		{"日本語", 2, 2, "本"},
+	}
+	for _, test := range tests {
+		if res := sliceColumns(test.line, test.start, test.width); res != test.expected {
+			t.Errorf("sliceColumns(%q, %d, %d) = %q, wanted %q", test.line, test.start, test.width, res, test.expected)
+		}
+	}
+}
+
+func TestHorizontalScroll(t *testing.T) {
+	var m tea.Model = NewModel(parse(t, ".Sh NAME\n"+strings.Repeat("word ", 20)))
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 30, Height: 20})
+	if longest := slices.Max(lineWidths(m.(model).lines)); longest <= 30 {
+		t.Errorf("rendered lines at most %d wide, wanted %d", longest, minContentWidth)
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	if x := m.(model).xOffset; x != horizontalStep {
+		t.Errorf("scrolled to column %d, wanted %d", x, horizontalStep)
+	}
+	for i := 0; i < 10; i++ {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	}
+	view, render := m.(model).contentWidths()
+	if x := m.(model).xOffset; x != render-view {
+		t.Errorf("scrolled to column %d, wanted to stop at %d", x, render-view)
+	}
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	if x := m.(model).xOffset; x != render-view-horizontalStep {
+		t.Errorf("scrolled back to column %d", x)
+	}
+}
+
+func TestFixedWidth(t *testing.T) {
+	defer func(width int) { pageWidth = width }(pageWidth)
+	pageWidth = 50
+	var m tea.Model = NewModel(parse(t, ".Sh NAME\n"+strings.Repeat("word ", 40)))
+	for _, window := range []int{40, 120} {
+		m, _ = m.Update(tea.WindowSizeMsg{Width: window, Height: 20})
+		if longest := slices.Max(lineWidths(m.(model).lines)); longest > pageWidth {
+			t.Errorf("in a %d column window, rendered lines %d wide, wanted at most %d", window, longest, pageWidth)
+		}
+		if _, render := m.(model).contentWidths(); render != pageWidth {
+			t.Errorf("in a %d column window, rendered at %d, wanted %d", window, render, pageWidth)
+		}
+	}
+}
+
+func lineWidths(lines []string) []int {
+	var widths []int
+	for _, line := range lines {
+		widths = append(widths, lipgloss.Width(line))
+	}
+	return widths
+}